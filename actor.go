@@ -0,0 +1,85 @@
+package goflow
+
+import "sync"
+
+type ActorContext interface {
+	Self() Ref
+	Sender() Ref
+	Become(StatefulBehavior)
+	Unbecome()
+}
+
+type StatefulBehavior interface {
+	Handle(ActorContext, interface{}) (interface{}, error)
+}
+
+type StatefulBehaviorFunc func(ActorContext, interface{}) (interface{}, error)
+
+func (f StatefulBehaviorFunc) Handle(ctx ActorContext, v interface{}) (interface{}, error) {
+	return f(ctx, v)
+}
+
+/* =================== */
+
+func NewStatefulBehaviorHandler(behavior StatefulBehavior, options ...BehaviorOption) BehaviorHandler {
+	return defaultSystem().NewStatefulBehaviorHandler(behavior, options...)
+}
+
+func newActor(behavior StatefulBehavior) *actor {
+	return &actor{
+		initial:   behavior,
+		behaviors: []StatefulBehavior{behavior},
+	}
+}
+
+type actor struct {
+	sync.Mutex
+	initial   StatefulBehavior
+	behaviors []StatefulBehavior
+}
+
+func (a *actor) receive(handler *behaviorHandler, call CallCommand) (interface{}, error) {
+	// serializes state transitions independent of the pool size
+	a.Lock()
+	defer a.Unlock()
+	ctx := &actorContext{
+		actor:   a,
+		handler: handler,
+		sender:  call.Sender,
+	}
+	return a.behaviors[len(a.behaviors)-1].Handle(ctx, call.Data)
+}
+
+func (a *actor) reset() {
+	a.Lock()
+	defer a.Unlock()
+	a.behaviors = []StatefulBehavior{a.initial}
+}
+
+type actorContext struct {
+	actor   *actor
+	handler *behaviorHandler
+	sender  Ref
+}
+
+func (ctx *actorContext) Self() Ref {
+	return ctx.handler.self()
+}
+
+func (ctx *actorContext) Sender() Ref {
+	return ctx.sender
+}
+
+// Become and Unbecome are only valid while Handle is running.
+func (ctx *actorContext) Become(behavior StatefulBehavior) {
+	if behavior == nil {
+		return
+	}
+	ctx.actor.behaviors = append(ctx.actor.behaviors, behavior)
+}
+
+func (ctx *actorContext) Unbecome() {
+	if l := len(ctx.actor.behaviors); l > 1 {
+		ctx.actor.behaviors = ctx.actor.behaviors[:l-1]
+	}
+}
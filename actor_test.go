@@ -0,0 +1,123 @@
+package goflow_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+// door answers with its state, open and close switch the behavior
+func door() goflow.StatefulBehavior {
+	var opened, closed goflow.StatefulBehaviorFunc
+	opened = func(ctx goflow.ActorContext, v interface{}) (interface{}, error) {
+		switch v {
+		case "close":
+			ctx.Unbecome()
+		case "panic":
+			panic("door panicked")
+		}
+		return fmt.Sprintf("opened:%v", v), nil
+	}
+	closed = func(ctx goflow.ActorContext, v interface{}) (interface{}, error) {
+		if v == "open" {
+			ctx.Become(opened)
+		}
+		return fmt.Sprintf("closed:%v", v), nil
+	}
+	return closed
+}
+
+func TestBecomeUnbecome(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewStatefulBehaviorHandler(door(), goflow.WithPoolSize(4))
+	defer handler.Stop()
+	ref := handler.Ref()
+
+	steps := []struct{ call, reply string }{
+		{"knock", "closed:knock"},
+		{"open", "closed:open"},
+		{"knock", "opened:knock"},
+		{"close", "opened:close"},
+		{"knock", "closed:knock"},
+	}
+	for _, step := range steps {
+		if v, err := request(t, ref, step.call); v != step.reply || err != nil {
+			t.Errorf("expected %v for %v, got %v, %v", step.reply, step.call, v, err)
+		}
+	}
+}
+
+func TestRestartResetsBehavior(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewStatefulBehaviorHandler(door(),
+		goflow.WithPoolSize(4),
+		goflow.WithSupervisor(goflow.RestartOnPanic()),
+	)
+	defer handler.Stop()
+	ref := handler.Ref()
+
+	if v, _ := request(t, ref, "open"); v != "closed:open" {
+		t.Fatalf("unexpected reply %v", v)
+	}
+	if _, err := request(t, ref, "panic"); err == nil {
+		t.Fatal("expected the panic as error")
+	}
+	// the restarted actor starts over with its initial behavior
+	if v, err := request(t, ref, "knock"); v != "closed:knock" || err != nil {
+		t.Errorf("expected closed:knock, got %v, %v", v, err)
+	}
+}
+
+type received struct {
+	msg    interface{}
+	sender goflow.Ref
+}
+
+func TestSelfAndSender(t *testing.T) {
+	sys := goflow.NewSystem()
+	inbox := make(chan received, 1)
+	recorder, err := sys.SpawnStateful("recorder", goflow.StatefulBehaviorFunc(func(ctx goflow.ActorContext, v interface{}) (interface{}, error) {
+		inbox <- received{v, ctx.Sender()}
+		return goflow.None(), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ponger, err := sys.SpawnStateful("ponger", goflow.StatefulBehaviorFunc(func(ctx goflow.ActorContext, v interface{}) (interface{}, error) {
+		if v == "ping" && ctx.Sender() != nil {
+			ctx.Sender().Tell("pong", ctx.Self())
+		}
+		return goflow.None(), nil
+	}), goflow.WithPoolSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ponger.Stop()
+	defer recorder.Stop()
+
+	recorderRef, _ := sys.Lookup("/user/recorder")
+	pongerRef, _ := sys.Lookup("/user/ponger")
+	pongerRef.Tell("ping", recorderRef)
+	select {
+	case r := <-inbox:
+		if r.msg != "pong" {
+			t.Errorf("expected pong, got %v", r.msg)
+		}
+		// Self is the ref the system hands out for the ponger
+		if r.sender != pongerRef {
+			t.Errorf("expected the ponger as sender, got %v", r.sender)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no pong received")
+	}
+
+	// calls without sender have none
+	if _, err := request(t, recorderRef, "anonymous"); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-inbox; r.sender != nil {
+		t.Errorf("expected no sender, got %v", r.sender)
+	}
+}
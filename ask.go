@@ -0,0 +1,30 @@
+package goflow
+
+import (
+	"context"
+	"time"
+)
+
+// Ask sends every element to ref and emits the replies in the order of the
+// elements, with up to parallelism requests in flight. A positive timeout
+// bounds every request and fails it with ErrorAskTimeout. Error replies and
+// timeouts are passed to the decider, by default they fail the stage.
+func Ask(ref Ref, parallelism int, timeout time.Duration, options ...FlowOption) Flow {
+	m := newMapAsync(parallelism, true, nil, options...)
+	m.f = func(v interface{}) (interface{}, error) {
+		if timeout <= 0 {
+			return ref.Request(v)
+		}
+		// the request is only cancelled by the timer of the clock
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		timer := m.currentClock().AfterFunc(timeout, cancel)
+		defer timer.Stop()
+		res, err := ref.RequestContext(ctx, v)
+		if err == context.Canceled {
+			return nil, ErrorAskTimeout
+		}
+		return res, err
+	}
+	return m
+}
@@ -0,0 +1,158 @@
+package goflow
+
+import "sync"
+
+type Balance interface {
+	Consumer
+	Branch() Graph
+}
+
+// NewBalance emits every element to exactly one attached branch with
+// demand, branches are served round robin.
+func NewBalance() Balance {
+	return &balance{}
+}
+
+type balance struct {
+	sync.Mutex
+	inlet      Inlet
+	branches   []*outletProducer
+	next       int
+	inflight   uint64
+	buffer     []interface{}
+	completing bool
+	completed  bool
+}
+
+func (b *balance) OnSubscribe(inlet Inlet) {
+	b.Lock()
+	defer b.Unlock()
+	b.inlet = inlet
+}
+
+func (b *balance) Branch() Graph {
+	b.Lock()
+	defer b.Unlock()
+	branch := newOutletProducer(b)
+	b.branches = append(b.branches, branch)
+	return NewGraph(branch)
+}
+
+func (b *balance) OnPush(v interface{}) {
+	b.Lock()
+	defer b.Unlock()
+	if b.inflight > 0 {
+		b.inflight--
+	}
+	b.buffer = append(b.buffer, v)
+	b.flush()
+	b.request()
+}
+
+func (b *balance) OnError(err error) {
+	b.Lock()
+	defer b.Unlock()
+	b.completed = true
+	b.buffer = nil
+	for _, branch := range b.branches {
+		if branch.attached {
+			branch.attached = false
+			branch.Error(err)
+		}
+	}
+}
+
+func (b *balance) OnComplete() {
+	b.Lock()
+	defer b.Unlock()
+	b.completing = true
+	b.flush()
+}
+
+func (b *balance) branchRequest(branch *outletProducer, n uint64) {
+	b.Lock()
+	defer b.Unlock()
+	if !branch.attached {
+		return
+	}
+	branch.demand = addDemand(branch.demand, n)
+	b.flush()
+	b.request()
+}
+
+func (b *balance) branchCancel(branch *outletProducer) {
+	b.Lock()
+	defer b.Unlock()
+	if !branch.attached {
+		return
+	}
+	branch.attached = false
+	branch.demand = 0
+	branch.Complete()
+	for _, other := range b.branches {
+		if other.attached {
+			b.flush()
+			return
+		}
+	}
+	if !b.completed {
+		b.completed = true
+		b.buffer = nil
+		if !b.completing {
+			b.inlet.Cancel()
+		}
+	}
+}
+
+// flush hands buffered elements to branches with demand and completes all
+// branches once upstream completed and the buffer is drained
+func (b *balance) flush() {
+	for len(b.buffer) > 0 {
+		branch := b.nextBranch()
+		if branch == nil {
+			break
+		}
+		branch.demand--
+		branch.Push(b.buffer[0])
+		b.buffer[0] = nil
+		b.buffer = b.buffer[1:]
+	}
+	if b.completing && len(b.buffer) == 0 && !b.completed {
+		b.completed = true
+		for _, branch := range b.branches {
+			if branch.attached {
+				branch.attached = false
+				branch.Complete()
+			}
+		}
+	}
+}
+
+func (b *balance) nextBranch() *outletProducer {
+	for i := 0; i < len(b.branches); i++ {
+		branch := b.branches[(b.next+i)%len(b.branches)]
+		if branch.attached && branch.demand > 0 {
+			b.next = (b.next + i + 1) % len(b.branches)
+			return branch
+		}
+	}
+	return nil
+}
+
+func (b *balance) request() {
+	if b.completed || b.completing || b.inlet == nil {
+		return
+	}
+	var demand uint64
+	for _, branch := range b.branches {
+		if branch.attached {
+			demand = addDemand(demand, branch.demand)
+		}
+	}
+	buffered := uint64(len(b.buffer))
+	if demand > addDemand(b.inflight, buffered) {
+		n := demand - b.inflight - buffered
+		b.inflight += n
+		b.inlet.Request(n)
+	}
+}
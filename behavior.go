@@ -2,6 +2,8 @@ package goflow
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 	"sync"
 )
 
@@ -23,51 +25,99 @@ type Ref interface {
 	Request(interface{}) (interface{}, error)
 	RequestContext(context.Context, interface{}) (interface{}, error)
 	Send(interface{})
+	Tell(interface{}, Ref)
 }
 
-func newRef(behaviorChan chan<- interface{}) Ref {
+func newRef(system *system, handler *behaviorHandler, mailboxSize int, concurrency int) Ref {
 	r := &ref{
-		inChan:       make(chan interface{}), // TODO configure size
-		closeChan:    make(chan bool),
-		behaviorChan: behaviorChan,
+		system:    system,
+		inChan:    make(chan interface{}, mailboxSize),
+		closeChan: make(chan bool),
+		pending:   make(chan pendingCall, concurrency),
+		handler:   handler,
 	}
 	r.run()
 	return r
 }
 
+type pendingCall struct {
+	call   CallCommand
+	origin *CallCommand
+}
+
 type ref struct {
 	sync.Mutex
-	inChan       chan interface{}
-	outChan      chan interface{}
-	closeChan    chan bool
-	behaviorChan chan<- interface{}
+	system    *system
+	inChan    chan interface{}
+	outChan   chan interface{}
+	closeChan chan bool
+	closeOnce sync.Once
+	pending   chan pendingCall
+	handler   *behaviorHandler
+}
+
+func (r *ref) forward(cmd interface{}) {
+	if r.handler.post(cmd) {
+		return
+	}
+	if ccmd, ok := cmd.(CallCommand); ok {
+		r.reject(ccmd, ErrorBehaviorStopped)
+	}
+}
+
+// reject replies err to a call that will never be handled and hands it to
+// the dead letters
+func (r *ref) reject(ccmd CallCommand, err error) {
+	ccmd.Reply(err)
+	r.system.deadLetter(ccmd.Data, r, err)
 }
 
 func (r *ref) run() {
 	go func() {
+		// relays results in the order the calls were forwarded, once OUT is observed
+		for p := range r.pending {
+			result := <-p.call.Result()
+			if p.origin != nil {
+				p.origin.Reply(result)
+			}
+			r.Lock()
+			outChan := r.outChan
+			r.Unlock()
+			if outChan != nil {
+				outChan <- result
+			}
+		}
+		r.Lock()
+		defer r.Unlock()
+		if r.outChan != nil {
+			close(r.outChan)
+		}
+	}()
+	go func() {
+		defer close(r.pending)
 		for {
 			select {
 			case <-r.closeChan:
-				close(r.outChan)
+				r.drain()
 				return
 			case cmd := <-r.inChan:
 				switch cmd.(type) {
 				case StopCommand:
-					r.behaviorChan <- cmd
+					r.forward(cmd)
 				case CallCommand:
 					cincmd := cmd.(CallCommand)
-					ccmd := Call(cincmd.Data)
-					r.behaviorChan <- ccmd
-					result := <-ccmd.Result()
-					cincmd.Reply(result)
-					if r.outChan != nil {
-						r.outChan <- result
+					if !r.observed() {
+						r.forward(cincmd)
+						continue
 					}
+					ccmd := CallFrom(cincmd.Data, cincmd.Sender)
+					r.forward(ccmd)
+					r.pending <- pendingCall{call: ccmd, origin: &cincmd}
 				default:
 					ccmd := Call(cmd)
-					r.behaviorChan <- ccmd
-					if r.outChan != nil {
-						r.outChan <- <-ccmd.Result()
+					r.forward(ccmd)
+					if r.observed() {
+						r.pending <- pendingCall{call: ccmd}
 					}
 				}
 			}
@@ -75,30 +125,69 @@ func (r *ref) run() {
 	}()
 }
 
+// drain rejects all calls left in the mailbox of a closed ref
+func (r *ref) drain() {
+	for {
+		select {
+		case cmd := <-r.inChan:
+			if ccmd, ok := cmd.(CallCommand); ok {
+				r.reject(ccmd, ErrorRefClosed)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (r *ref) observed() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.outChan != nil
+}
+
 func (r *ref) IN() chan<- interface{} {
 	return r.inChan
 }
 
 func (r *ref) OUT() <-chan interface{} {
+	r.Lock()
+	defer r.Unlock()
 	if r.outChan == nil {
-		r.Lock()
-		defer r.Unlock()
-		r.outChan = make(chan interface{}) // TODO configure size
+		r.outChan = make(chan interface{}, cap(r.inChan))
 	}
 	return r.outChan
 }
 
+func (r *ref) closing() <-chan bool {
+	return r.closeChan
+}
+
 func (r *ref) Close() {
-	r.closeChan <- true
-	close(r.closeChan)
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+	})
 }
 
 func (r *ref) RequestChan(v interface{}) <-chan interface{} {
 	ccmd := Call(v)
-	r.inChan <- ccmd
+	r.enqueue(ccmd)
 	return ccmd.Result()
 }
 
+func (r *ref) enqueue(ccmd CallCommand) {
+	select {
+	case <-r.closeChan:
+		r.reject(ccmd, ErrorRefClosed)
+		return
+	default:
+	}
+	select {
+	case r.inChan <- ccmd:
+	case <-r.closeChan:
+		r.reject(ccmd, ErrorRefClosed)
+	}
+}
+
 func (r *ref) Request(v interface{}) (interface{}, error) {
 	return r.RequestContext(context.Background(), v)
 }
@@ -117,66 +206,315 @@ func (r *ref) RequestContext(ctx context.Context, v interface{}) (interface{}, e
 }
 
 func (r *ref) Send(v interface{}) {
-	go func() {
-		r.RequestChan(v)
-	}()
+	go r.enqueue(Call(v))
+}
+
+func (r *ref) Tell(v interface{}, sender Ref) {
+	go r.enqueue(CallFrom(v, sender))
 }
 
 type BehaviorHandler interface {
 	Ref() Ref
+	Name() string
+	Path() string
+	Spawn(string, Behavior, ...BehaviorOption) (BehaviorHandler, error)
+	SpawnStateful(string, StatefulBehavior, ...BehaviorOption) (BehaviorHandler, error)
+	Child(string) (BehaviorHandler, bool)
+	Children() []BehaviorHandler
+	Stop()
+	Terminated() <-chan struct{}
+}
+
+type BehaviorOption func(*behaviorHandler)
+
+func WithPoolSize(size int) BehaviorOption {
+	return func(bh *behaviorHandler) {
+		if size > 0 {
+			bh.poolSize = size
+		}
+	}
+}
+
+func WithMailboxSize(size int) BehaviorOption {
+	return func(bh *behaviorHandler) {
+		if size >= 0 {
+			bh.mailboxSize = size
+		}
+	}
+}
+
+func NewBehaviorHandler(behavior Behavior, options ...BehaviorOption) BehaviorHandler {
+	return defaultSystem().NewBehaviorHandler(behavior, options...)
+}
+
+func newAnonymousBehaviorHandler(system *system, behavior Behavior, actor *actor, options ...BehaviorOption) *behaviorHandler {
+	bh := newBehaviorHandler(behavior, actor, append([]BehaviorOption{func(bh *behaviorHandler) {
+		bh.anonymous = true
+		bh.system = system
+	}}, options...)...)
+	system.resources.register(bh)
+	return bh
 }
 
-func NewBehaviorHandler(behavior Behavior) BehaviorHandler {
+func newBehaviorHandler(behavior Behavior, actor *actor, options ...BehaviorOption) *behaviorHandler {
 	bh := &behaviorHandler{
-		channel:  make(chan interface{}), // TODO configure size
-		behavior: behavior,
-		poolSize: 1, // TODO configure poolSize
+		behavior:   behavior,
+		actor:      actor,
+		poolSize:   1,
+		supervisor: RestartOnPanic(),
+		stopped:    make(chan struct{}),
+		terminated: make(chan struct{}),
+		children:   make(map[string]*behaviorHandler),
 	}
+	for _, option := range options {
+		option(bh)
+	}
+	bh.channel = make(chan interface{}, bh.mailboxSize)
 	bh.run()
 	return bh
 }
 
 type behaviorHandler struct {
-	channel  chan interface{}
-	behavior Behavior
-	poolSize int
-	jobs     chan CallCommand
+	sync.RWMutex
+	name        string
+	anonymous   bool
+	system      *system
+	owner       *behaviorHandler
+	children    map[string]*behaviorHandler
+	channel     chan interface{}
+	behavior    Behavior
+	actor       *actor
+	poolSize    int
+	mailboxSize int
+	supervisor  SupervisorStrategy
+	parent      Ref
+	jobs        chan CallCommand
+	stopped     chan struct{}
+	terminated  chan struct{}
+	workers     sync.WaitGroup
+	mailboxLock sync.RWMutex
+	sealed      bool
+	stopOnce    sync.Once
+	selfOnce    sync.Once
+	selfRef     Ref
 }
 
-func worker(num int, jobs <-chan CallCommand, behavior Behavior) {
-	for call := range jobs {
-		res, err := behavior.Handle(call.Data)
-		if err != nil {
-			call.Reply(err)
+func (b *behaviorHandler) worker(num int, restarts int) {
+	defer b.workers.Done()
+	for call := range b.jobs {
+		failure := b.handle(call)
+		if failure == nil {
+			restarts = 0
 			continue
 		}
-		call.Reply(res)
+		directive, delay := b.supervisor.Decide(failure, restarts)
+		b.system.WithField("behavior", b.resourceName()).ERROR("Behavior panicked: %v", failure.Meta["panic"])
+		switch directive {
+		case ResumeDirective:
+			// keeps the current state and continues with the next call
+			continue
+		case RestartDirective:
+			if b.actor != nil {
+				b.actor.reset()
+			}
+			b.workers.Add(1)
+			if delay > 0 {
+				b.system.clock.AfterFunc(delay, func() {
+					go b.worker(num, restarts+1)
+				})
+				return
+			}
+			go b.worker(num, restarts+1)
+			return
+		case EscalateDirective:
+			if b.parent != nil {
+				b.parent.Tell(Failure(failure), b.self())
+			}
+			b.stop()
+		default:
+			b.stop()
+		}
+		for call := range b.jobs {
+			b.reject(call)
+		}
+		return
 	}
 }
 
+// reject replies to a call the stopped handler will never process and
+// hands it to the dead letters
+func (b *behaviorHandler) reject(call CallCommand) {
+	call.Reply(ErrorBehaviorStopped)
+	b.system.deadLetter(call.Data, b, ErrorBehaviorStopped)
+}
+
+// post puts cmd into the mailbox, it returns false if the handler is
+// stopped. Posting and sealing exclude each other, so nothing is posted
+// after the last drain.
+func (b *behaviorHandler) post(cmd interface{}) bool {
+	b.mailboxLock.RLock()
+	defer b.mailboxLock.RUnlock()
+	if b.sealed {
+		return false
+	}
+	select {
+	case b.channel <- cmd:
+		return true
+	case <-b.stopped:
+		return false
+	}
+}
+
+// seal waits for running posts, which give up once the handler is
+// stopped, and rejects all later ones
+func (b *behaviorHandler) seal() {
+	b.mailboxLock.Lock()
+	defer b.mailboxLock.Unlock()
+	b.sealed = true
+}
+
+// drain rejects the calls left in the mailbox of a stopped handler
+func (b *behaviorHandler) drain() {
+	for {
+		select {
+		case cmd, open := <-b.channel:
+			if !open {
+				return
+			}
+			if call, ok := cmd.(CallCommand); ok {
+				b.reject(call)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (b *behaviorHandler) handle(call CallCommand) (failure *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = panicError(r, debug.Stack()).WithTimestamp(b.system.clock.Now())
+			call.Reply(failure)
+		}
+	}()
+	res, err := b.invoke(call)
+	if err != nil {
+		call.Reply(err)
+		return nil
+	}
+	call.Reply(res)
+	return nil
+}
+
+func (b *behaviorHandler) invoke(call CallCommand) (interface{}, error) {
+	if b.actor != nil {
+		return b.actor.receive(b, call)
+	}
+	return b.behavior.Handle(call.Data)
+}
+
+func (b *behaviorHandler) self() Ref {
+	b.selfOnce.Do(func() {
+		b.selfRef = b.Ref()
+	})
+	return b.selfRef
+}
+
+func (b *behaviorHandler) stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopped)
+		go b.terminate()
+	})
+}
+
+func (b *behaviorHandler) terminate() {
+	for _, child := range b.childHandlers() {
+		child.stop()
+		<-child.terminated
+	}
+	b.workers.Wait()
+	// refs may still have forwarded calls while the handler was stopping
+	b.seal()
+	b.drain()
+	// the shared ref is handed out by Lookup and to children, its
+	// goroutines end with the handler
+	b.self().Close()
+	if b.owner != nil {
+		b.owner.removeChild(b)
+	}
+	close(b.terminated)
+}
+
 func (b *behaviorHandler) run() {
 	b.jobs = make(chan CallCommand, b.poolSize)
+	b.workers.Add(b.poolSize)
 	for i := 0; i < b.poolSize; i++ {
-		go worker(i, b.jobs, b.behavior)
+		go b.worker(i, 0)
 	}
 	go func() {
+		defer close(b.jobs)
+		defer b.drain()
 		for {
-			cmd, open := <-b.channel
-			if !open {
-				return
-			}
-			switch cmd.(type) {
-			case StopCommand:
-				close(b.jobs)
+			select {
+			case <-b.stopped:
 				return
-			case CallCommand:
-				call := cmd.(CallCommand)
-				b.jobs <- call
+			case cmd, open := <-b.channel:
+				if !open {
+					return
+				}
+				switch cmd.(type) {
+				case StopCommand:
+					b.stop()
+					return
+				case CallCommand:
+					call := cmd.(CallCommand)
+					select {
+					case b.jobs <- call:
+					case <-b.stopped:
+						b.reject(call)
+						return
+					}
+				}
 			}
 		}
 	}()
 }
 
 func (b *behaviorHandler) Ref() Ref {
-	return newRef(b.channel)
+	return newRef(b.system, b, b.mailboxSize, b.poolSize)
+}
+
+func (b *behaviorHandler) Stop() {
+	b.stop()
+}
+
+func (b *behaviorHandler) Terminated() <-chan struct{} {
+	return b.terminated
+}
+
+func (b *behaviorHandler) resourceName() string {
+	if b.anonymous {
+		return fmt.Sprintf("behavior(%p)", b)
+	}
+	return b.Path()
+}
+
+func (b *behaviorHandler) shutdown() {
+	b.stop()
+}
+
+func (b *behaviorHandler) kill() {
+	b.stop()
+	for _, child := range b.childHandlers() {
+		child.kill()
+	}
+	go func() {
+		for call := range b.jobs {
+			b.reject(call)
+		}
+	}()
+}
+
+func (b *behaviorHandler) done() <-chan struct{} {
+	return b.terminated
 }
@@ -0,0 +1,73 @@
+package goflow_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func echo() goflow.Behavior {
+	return goflow.BehaviorFunc(func(v interface{}) (interface{}, error) {
+		return v, nil
+	})
+}
+
+// requestAll sends n concurrent requests and collects their errors, a
+// request that does not return within a second counts as hanging
+func requestAll(ref goflow.Ref, n int) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, errs[i] = ref.RequestContext(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestStoppedBufferedMailboxRejects(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewBehaviorHandler(echo(), goflow.WithMailboxSize(8))
+	ref := handler.Ref()
+	handler.Stop()
+	<-handler.Terminated()
+
+	for i, err := range requestAll(ref, 20) {
+		if err != goflow.ErrorBehaviorStopped {
+			t.Errorf("request %v: expected %v, got %v", i, goflow.ErrorBehaviorStopped, err)
+		}
+	}
+}
+
+func TestStopRejectsQueuedCalls(t *testing.T) {
+	sys := goflow.NewSystem()
+	release := make(chan struct{})
+	handler := sys.NewBehaviorHandler(goflow.BehaviorFunc(func(v interface{}) (interface{}, error) {
+		<-release
+		return v, nil
+	}), goflow.WithMailboxSize(8))
+	ref := handler.Ref()
+
+	errs := make(chan []error)
+	go func() {
+		errs <- requestAll(ref, 20)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	handler.Stop()
+	close(release)
+	<-handler.Terminated()
+
+	for i, err := range <-errs {
+		if err != nil && err != goflow.ErrorBehaviorStopped {
+			t.Errorf("request %v: expected a reply or %v, got %v", i, goflow.ErrorBehaviorStopped, err)
+		}
+	}
+}
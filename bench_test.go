@@ -0,0 +1,138 @@
+package goflow
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchmarkPipeline runs a single pipeline of b.N elements, so ns/op,
+// B/op and allocs/op are reported per element
+func benchmarkPipeline(b *testing.B, run func(n uint64) <-chan interface{}) {
+	b.ReportAllocs()
+	var peak int64
+	done := make(chan struct{})
+	go sampleGoroutines(&peak, done)
+	b.ResetTimer()
+	<-run(uint64(b.N))
+	b.StopTimer()
+	close(done)
+	b.ReportMetric(float64(atomic.LoadInt64(&peak)), "max-goroutines")
+}
+
+func sampleGoroutines(peak *int64, done <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Microsecond)
+	defer ticker.Stop()
+	for {
+		n := int64(runtime.NumGoroutine())
+		if n > atomic.LoadInt64(peak) {
+			atomic.StoreInt64(peak, n)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func BenchmarkSource(b *testing.B) {
+	benchmarkPipeline(b, func(n uint64) <-chan interface{} {
+		return Sequence().Take(n).To(Ignore()).Run()
+	})
+}
+
+func BenchmarkMap(b *testing.B) {
+	benchmarkPipeline(b, func(n uint64) <-chan interface{} {
+		return Sequence().Take(n).
+			Via(Map(func(v interface{}) interface{} { return v.(uint64) * 2 })).
+			To(Ignore()).Run()
+	})
+}
+
+func BenchmarkMapFilterMap(b *testing.B) {
+	benchmarkPipeline(b, func(n uint64) <-chan interface{} {
+		return Sequence().Take(n).
+			Via(Map(func(v interface{}) interface{} { return v.(uint64) * 2 })).
+			Via(Filter(func(v interface{}) bool { return v.(uint64)%3 != 0 })).
+			Via(Map(func(v interface{}) interface{} { return v.(uint64) + 1 })).
+			To(Ignore()).Run()
+	})
+}
+
+func BenchmarkReflectMap(b *testing.B) {
+	benchmarkPipeline(b, func(n uint64) <-chan interface{} {
+		return Sequence().Take(n).
+			Map(func(v uint64) uint64 { return v * 2 }).
+			To(Ignore()).Run()
+	})
+}
+
+func BenchmarkSlice(b *testing.B) {
+	benchmarkPipeline(b, func(n uint64) <-chan interface{} {
+		return Sequence().Take(n).To(Slice()).Run()
+	})
+}
+
+/* =================== */
+
+// pingProducer and pongConsumer exchange one element per request, the
+// pattern of pull-by-one stages like BehaviorConsumer
+type pingProducer struct {
+	outlet Outlet
+}
+
+func (p *pingProducer) Subscribe(outlet Outlet) { p.outlet = outlet }
+func (p *pingProducer) OnRequest(n uint64) {
+	for i := uint64(0); i < n; i++ {
+		p.outlet.Push(i)
+	}
+}
+func (p *pingProducer) OnCancel() {}
+
+type pongConsumer struct {
+	inlet Inlet
+	left  int
+	done  chan struct{}
+}
+
+func (c *pongConsumer) OnSubscribe(inlet Inlet) { c.inlet = inlet }
+func (c *pongConsumer) OnPush(interface{}) {
+	if c.left--; c.left == 0 {
+		close(c.done)
+		return
+	}
+	c.inlet.Request(1)
+}
+func (c *pongConsumer) OnError(error) {}
+func (c *pongConsumer) OnComplete()   {}
+
+// benchmarkPingPong passes b.N elements one by one between two stages
+// whose mailboxes are created by newBox
+func benchmarkPingPong(b *testing.B, newBox func() *mailbox) {
+	b.ReportAllocs()
+	p := newGraph(&pingProducer{}, newBox())
+	c := &pongConsumer{left: b.N, done: make(chan struct{})}
+	p.consumer = c
+	p.downstream = newBox()
+	c.OnSubscribe(p)
+	b.ResetTimer()
+	p.Request(1)
+	<-c.done
+	b.StopTimer()
+	p.Complete()
+}
+
+// BenchmarkMailbox compares the goroutine per stage with the former
+// goroutine per busy period, which transient mailboxes still use
+func BenchmarkMailbox(b *testing.B) {
+	b.Run("stage", func(b *testing.B) {
+		benchmarkPingPong(b, newMailbox)
+	})
+	b.Run("transient", func(b *testing.B) {
+		benchmarkPingPong(b, func() *mailbox {
+			return &mailbox{transient: true}
+		})
+	})
+}
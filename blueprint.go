@@ -0,0 +1,277 @@
+package goflow
+
+import (
+	"io"
+	"sync"
+)
+
+type Materialized[V any] interface {
+	// Cancel stops the run, the result is delivered as usual
+	Cancel()
+	Done() <-chan struct{}
+	// Wait blocks till the run completes and returns the result of the
+	// consumer, errors are returned as error
+	Wait() (interface{}, error)
+	// Value returns the value materialized by the producer, e.g. a Queue
+	Value() V
+}
+
+type Blueprint[V any] interface {
+	Take(uint64) Blueprint[V]
+	Map(interface{}) Blueprint[V]
+	Filter(interface{}) Blueprint[V]
+	Via(FlowGraph) Blueprint[V]
+	To(func() RunnableConsumer) RunnableBlueprint[V]
+}
+
+type RunnableBlueprint[V any] interface {
+	Run() Materialized[V]
+	RunIn(FlowSystem) Materialized[V]
+}
+
+// NewBlueprint describes a graph which is created by source on every run.
+func NewBlueprint(source func() Graph) Blueprint[struct{}] {
+	return NewMaterializingBlueprint(func(FlowSystem) (Graph, struct{}) {
+		return source(), struct{}{}
+	})
+}
+
+// NewMaterializingBlueprint describes a graph whose source also returns a
+// value for every run, the value is available by Materialized.Value.
+func NewMaterializingBlueprint[V any](source func(FlowSystem) (Graph, V)) Blueprint[V] {
+	return &blueprint[V]{
+		source: source,
+		flow:   NewFlowGraph(),
+	}
+}
+
+type blueprint[V any] struct {
+	source func(FlowSystem) (Graph, V)
+	flow   FlowGraph
+}
+
+func (bp *blueprint[V]) with(flow FlowGraph) Blueprint[V] {
+	return &blueprint[V]{
+		source: bp.source,
+		flow:   flow,
+	}
+}
+
+func (bp *blueprint[V]) Take(n uint64) Blueprint[V] {
+	return bp.with(bp.flow.Take(n))
+}
+
+func (bp *blueprint[V]) Map(f interface{}) Blueprint[V] {
+	return bp.with(bp.flow.Map(f))
+}
+
+func (bp *blueprint[V]) Filter(f interface{}) Blueprint[V] {
+	return bp.with(bp.flow.Filter(f))
+}
+
+func (bp *blueprint[V]) Via(flow FlowGraph) Blueprint[V] {
+	return bp.with(bp.flow.Append(flow))
+}
+
+func (bp *blueprint[V]) To(consumer func() RunnableConsumer) RunnableBlueprint[V] {
+	return &runnableBlueprint[V]{
+		blueprint: bp,
+		consumer:  consumer,
+	}
+}
+
+type runnableBlueprint[V any] struct {
+	blueprint *blueprint[V]
+	consumer  func() RunnableConsumer
+}
+
+func (rb *runnableBlueprint[V]) Run() Materialized[V] {
+	return rb.RunIn(System())
+}
+
+func (rb *runnableBlueprint[V]) RunIn(system FlowSystem) Materialized[V] {
+	source, value := rb.blueprint.source(system)
+	runnable := rb.blueprint.flow.WithProducer(source).To(rb.consumer())
+	m := &materialized[V]{
+		runnable: runnable,
+		value:    value,
+		done:     make(chan struct{}),
+	}
+	result := system.Run(runnable)
+	go func() {
+		m.result = <-result
+		close(m.done)
+	}()
+	return m
+}
+
+type materialized[V any] struct {
+	runnable Runnable
+	value    V
+	result   interface{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (m *materialized[V]) Cancel() {
+	m.once.Do(m.runnable.Close)
+}
+
+func (m *materialized[V]) Done() <-chan struct{} {
+	return m.done
+}
+
+func (m *materialized[V]) Wait() (interface{}, error) {
+	<-m.done
+	if err, ok := m.result.(error); ok {
+		return nil, err
+	}
+	return m.result, nil
+}
+
+func (m *materialized[V]) Value() V {
+	return m.value
+}
+
+/* =================== */
+
+type Queue interface {
+	// Offer enqueues v, it fails with ErrorQueueFull if the buffer is full
+	// or ErrorQueueClosed after Complete or Fail
+	Offer(interface{}) error
+	Complete()
+	Fail(error)
+}
+
+// QueueBlueprint materializes a Queue which feeds the graph of every run.
+func QueueBlueprint(size int) Blueprint[Queue] {
+	return NewMaterializingBlueprint(func(FlowSystem) (Graph, Queue) {
+		q := &queue{
+			elements:  make(chan interface{}, size),
+			cancelled: make(chan struct{}),
+		}
+		return NewProducer(q), q
+	})
+}
+
+type queue struct {
+	sync.RWMutex
+	elements   chan interface{}
+	closed     bool
+	failure    error
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+}
+
+func (q *queue) Offer(v interface{}) error {
+	q.RLock()
+	defer q.RUnlock()
+	if q.closed {
+		return ErrorQueueClosed
+	}
+	select {
+	case q.elements <- v:
+		return nil
+	default:
+		return ErrorQueueFull
+	}
+}
+
+// close keeps the failure aside, it is reported once the buffered
+// elements are pulled
+func (q *queue) close(failure error) {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.failure = failure
+	close(q.elements)
+}
+
+func (q *queue) Complete() {
+	q.close(nil)
+}
+
+func (q *queue) Fail(err error) {
+	q.close(err)
+}
+
+func (q *queue) OnInit() {}
+
+func (q *queue) OnPull() (interface{}, error) {
+	select {
+	case v, open := <-q.elements:
+		if !open {
+			q.RLock()
+			defer q.RUnlock()
+			if q.failure != nil {
+				return nil, q.failure
+			}
+			return nil, io.EOF
+		}
+		return v, nil
+	case <-q.cancelled:
+		return nil, io.EOF
+	}
+}
+
+func (q *queue) OnClose() {
+	q.cancel()
+	q.Complete()
+}
+
+func (q *queue) cancel() {
+	q.cancelOnce.Do(func() {
+		close(q.cancelled)
+	})
+}
+
+/* =================== */
+
+// TopicBlueprint materializes a Topic for every run, all messages
+// published to the topic are emitted till the topic is closed.
+func TopicBlueprint() Blueprint[Topic] {
+	return NewMaterializingBlueprint(func(system FlowSystem) (Graph, Topic) {
+		t := system.NewTopic()
+		in := make(chan interface{})
+		t.Subscribe(in)
+		return NewProducer(&topicSource{
+			topic:     t,
+			in:        in,
+			cancelled: make(chan struct{}),
+		}), t
+	})
+}
+
+type topicSource struct {
+	topic      Topic
+	in         chan interface{}
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+}
+
+func (ts *topicSource) OnInit() {}
+
+func (ts *topicSource) OnPull() (interface{}, error) {
+	select {
+	case v := <-ts.in:
+		return v, nil
+	case <-ts.topic.Done():
+		return nil, io.EOF
+	case <-ts.cancelled:
+		return nil, io.EOF
+	}
+}
+
+func (ts *topicSource) OnClose() {
+	ts.cancel()
+	ts.topic.Unsubscribe(ts.in)
+}
+
+func (ts *topicSource) cancel() {
+	ts.cancelOnce.Do(func() {
+		close(ts.cancelled)
+	})
+}
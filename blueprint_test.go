@@ -0,0 +1,162 @@
+package goflow_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func wait[V any](t *testing.T, m goflow.Materialized[V]) (interface{}, error) {
+	t.Helper()
+	select {
+	case <-m.Done():
+		return m.Wait()
+	case <-time.After(time.Second):
+		t.Fatal("run did not complete")
+		return nil, nil
+	}
+}
+
+func TestBlueprintRunsRepeatedly(t *testing.T) {
+	sys := goflow.NewSystem()
+	bp := goflow.NewBlueprint(goflow.Sequence).
+		Take(3).
+		Map(func(v uint64) uint64 { return v * 2 }).
+		To(goflow.Slice)
+
+	for i := 0; i < 3; i++ {
+		v, err := wait(t, bp.RunIn(sys))
+		if err != nil {
+			t.Fatalf("run %v failed with %v", i, err)
+		}
+		if !reflect.DeepEqual(v, []interface{}{uint64(0), uint64(2), uint64(4)}) {
+			t.Errorf("run %v returned %v", i, v)
+		}
+	}
+}
+
+func TestQueueBlueprint(t *testing.T) {
+	sys := goflow.NewSystem()
+	bp := goflow.QueueBlueprint(2).To(goflow.Slice)
+
+	t.Run("offer and complete", func(t *testing.T) {
+		m := bp.RunIn(sys)
+		q := m.Value()
+		for _, v := range []string{"a", "b"} {
+			if err := q.Offer(v); err != nil {
+				t.Fatalf("offer %v failed with %v", v, err)
+			}
+		}
+		q.Complete()
+		if err := q.Offer("c"); err != goflow.ErrorQueueClosed {
+			t.Errorf("expected %v, got %v", goflow.ErrorQueueClosed, err)
+		}
+		v, err := wait(t, m)
+		if err != nil || !reflect.DeepEqual(v, []interface{}{"a", "b"}) {
+			t.Errorf("unexpected result %v, %v", v, err)
+		}
+	})
+
+	t.Run("full buffer", func(t *testing.T) {
+		// the consumer blocks on the first element, the pipeline and the
+		// buffer fill up behind it
+		handler := sys.NewBehaviorHandler(&slowAck{d: time.Second})
+		defer handler.Stop()
+		m := goflow.QueueBlueprint(1).To(func() goflow.RunnableConsumer {
+			return goflow.BehaviorConsumer(handler.Ref(), goflow.Done())
+		}).RunIn(sys)
+		defer m.Cancel()
+		q := m.Value()
+		for i := 0; q.Offer(i) != goflow.ErrorQueueFull; i++ {
+			if i > 100 {
+				t.Fatal("queue never reported a full buffer")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		failure := errors.New("failed")
+		m := bp.RunIn(sys)
+		q := m.Value()
+		q.Offer("a")
+		q.Fail(failure)
+		if err := q.Offer("b"); err != goflow.ErrorQueueClosed {
+			t.Errorf("expected %v, got %v", goflow.ErrorQueueClosed, err)
+		}
+		if _, err := wait(t, m); err != failure {
+			t.Errorf("expected %v, got %v", failure, err)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		m := bp.RunIn(sys)
+		m.Cancel()
+		if _, err := wait(t, m); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := m.Value().Offer("a"); err != goflow.ErrorQueueClosed {
+			t.Errorf("expected %v, got %v", goflow.ErrorQueueClosed, err)
+		}
+	})
+
+	t.Run("independent runs", func(t *testing.T) {
+		first, second := bp.RunIn(sys), bp.RunIn(sys)
+		first.Value().Offer("first")
+		second.Value().Offer("second")
+		first.Value().Complete()
+		second.Value().Complete()
+		for m, expected := range map[goflow.Materialized[goflow.Queue]]string{first: "first", second: "second"} {
+			v, err := wait(t, m)
+			if err != nil || !reflect.DeepEqual(v, []interface{}{expected}) {
+				t.Errorf("expected [%v], got %v, %v", expected, v, err)
+			}
+		}
+	})
+}
+
+func TestTopicBlueprint(t *testing.T) {
+	sys := goflow.NewSystem()
+	bp := goflow.TopicBlueprint().To(goflow.Slice)
+
+	t.Run("publish order", func(t *testing.T) {
+		m := bp.RunIn(sys)
+		topic := m.Value()
+		expected := make([]interface{}, 100)
+		for i := range expected {
+			expected[i] = i
+			topic.Publish(i)
+		}
+		// messages still queued on Close are dead-lettered
+		time.Sleep(50 * time.Millisecond)
+		topic.Close()
+		v, err := wait(t, m)
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("expected messages in publish order, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("runs repeatedly", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			m := bp.RunIn(sys)
+			m.Value().Publish(i)
+			time.Sleep(20 * time.Millisecond)
+			m.Value().Close()
+			v, err := wait(t, m)
+			if err != nil || !reflect.DeepEqual(v, []interface{}{i}) {
+				t.Errorf("run %v returned %v, %v", i, v, err)
+			}
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		m := bp.RunIn(sys)
+		m.Cancel()
+		if _, err := wait(t, m); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package goflow
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of time based stages. Replace it to control
+// time, e.g. in tests.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(time.Duration, func()) Timer
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// sleep blocks for d on clock
+func sleep(clock Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	clock.AfterFunc(d, func() {
+		close(done)
+	})
+	<-done
+}
+
+/* =================== */
+
+// ManualClock is a Clock that only moves when it is advanced, timers fire
+// synchronously within Advance in the order they are due. Timer functions
+// must not block.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{
+		now: start,
+	}
+}
+
+type ManualClock struct {
+	sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	due   time.Time
+	f     func()
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.Lock()
+	defer c.Unlock()
+	t := &manualTimer{
+		clock: c,
+		due:   c.now.Add(d),
+		f:     f,
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer due on the
+// way, including timers scheduled by fired timers.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Lock()
+	target := c.now.Add(d)
+	c.Unlock()
+	for {
+		c.Lock()
+		next := -1
+		for i, t := range c.timers {
+			if !t.due.After(target) && (next < 0 || t.due.Before(c.timers[next].due)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			c.now = target
+			c.Unlock()
+			return
+		}
+		t := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if t.due.After(c.now) {
+			c.now = t.due
+		}
+		c.Unlock()
+		t.f()
+	}
+}
+
+// Pending returns the number of timers that did not fire yet.
+func (c *ManualClock) Pending() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.timers)
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+	for i, other := range t.clock.timers {
+		if other == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+package goflow_test
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func manualSystem() (*goflow.ManualClock, goflow.FlowSystem) {
+	clock := goflow.NewManualClock(time.Unix(0, 0))
+	return clock, goflow.NewSystem(goflow.WithSystemClock(clock))
+}
+
+// awaitTimers waits till the stages scheduled at least n timers
+func awaitTimers(t *testing.T, clock *goflow.ManualClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Pending() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v pending timers, got %v", n, clock.Pending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func awaitResult(t *testing.T, result <-chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case v := <-result:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("stream did not complete")
+		return nil
+	}
+}
+
+func expectPending(t *testing.T, result <-chan interface{}) {
+	t.Helper()
+	select {
+	case v := <-result:
+		t.Fatalf("stream completed early with %v", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDelayManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	result := sys.Run(goflow.Sequence().Take(3).Via(goflow.Delay(time.Hour)).To(goflow.Slice()))
+
+	awaitTimers(t, clock, 1)
+	clock.Advance(30 * time.Minute)
+	expectPending(t, result)
+
+	clock.Advance(30 * time.Minute)
+	if v := awaitResult(t, result); !reflect.DeepEqual(v, []interface{}{uint64(0), uint64(1), uint64(2)}) {
+		t.Errorf("unexpected result %v", v)
+	}
+}
+
+func TestThrottleManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	result := sys.Run(goflow.Sequence().Take(3).Via(goflow.Throttle(1, time.Minute, 1)).To(goflow.Slice()))
+
+	for i := 0; i < 2; i++ {
+		awaitTimers(t, clock, 1)
+		expectPending(t, result)
+		clock.Advance(time.Minute)
+	}
+	if v := awaitResult(t, result); !reflect.DeepEqual(v, []interface{}{uint64(0), uint64(1), uint64(2)}) {
+		t.Errorf("unexpected result %v", v)
+	}
+}
+
+func TestIdleTimeoutManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	result := sys.Run(goflow.ChanProducer(make(chan interface{})).Via(goflow.IdleTimeout(time.Minute)).To(goflow.Slice()))
+
+	awaitTimers(t, clock, 1)
+	clock.Advance(59 * time.Second)
+	expectPending(t, result)
+
+	clock.Advance(time.Second)
+	if v := awaitResult(t, result); v != goflow.ErrorIdleTimeout {
+		t.Errorf("expected %v, got %v", goflow.ErrorIdleTimeout, v)
+	}
+}
+
+func TestCompletionTimeoutManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	result := sys.Run(goflow.Sequence().Via(goflow.CompletionTimeout(time.Minute)).To(goflow.Ignore()))
+
+	awaitTimers(t, clock, 1)
+	clock.Advance(time.Minute)
+	if v := awaitResult(t, result); v != goflow.ErrorCompletionTimeout {
+		t.Errorf("expected %v, got %v", goflow.ErrorCompletionTimeout, v)
+	}
+}
+
+/* =================== */
+
+// stepper emits the elements passed to send one by one, send returns once
+// the stage handled the element and asked for the next one
+type stepper struct {
+	pulled chan struct{}
+	in     chan interface{}
+}
+
+func newStepper() *stepper {
+	return &stepper{
+		pulled: make(chan struct{}),
+		in:     make(chan interface{}),
+	}
+}
+
+func (s *stepper) graph() goflow.Graph {
+	return goflow.NewProducerFunc(func() (interface{}, error) {
+		s.pulled <- struct{}{}
+		v, open := <-s.in
+		if !open {
+			return nil, io.EOF
+		}
+		return v, nil
+	})
+}
+
+func (s *stepper) await(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.pulled:
+	case <-time.After(time.Second):
+		t.Fatal("stage did not request the next element")
+	}
+}
+
+func (s *stepper) send(t *testing.T, values ...interface{}) {
+	t.Helper()
+	for _, v := range values {
+		s.in <- v
+		s.await(t)
+	}
+}
+
+func (s *stepper) complete() {
+	close(s.in)
+}
+
+// emitted runs the stage fed by a stepper and collects its elements
+func emitted(sys goflow.FlowSystem, flow goflow.Flow) (*stepper, <-chan interface{}, <-chan interface{}) {
+	src := newStepper()
+	out := make(chan interface{}, 16)
+	result := sys.Run(src.graph().Via(flow).To(goflow.ForEach(func(v interface{}) {
+		out <- v
+	})))
+	return src, out, result
+}
+
+func expectElement(t *testing.T, out <-chan interface{}, expected interface{}) {
+	t.Helper()
+	select {
+	case v := <-out:
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("expected %v, got %v", expected, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected %v, got nothing", expected)
+	}
+}
+
+func expectNoElement(t *testing.T, out <-chan interface{}) {
+	t.Helper()
+	select {
+	case v := <-out:
+		t.Fatalf("unexpected element %v", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDebounceManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	src, out, result := emitted(sys, goflow.Debounce(time.Minute))
+	src.await(t)
+
+	src.send(t, "a", "b")
+	clock.Advance(59 * time.Second)
+	expectNoElement(t, out)
+	// every element restarts the quiet period
+	src.send(t, "c")
+	clock.Advance(59 * time.Second)
+	expectNoElement(t, out)
+	clock.Advance(time.Second)
+	expectElement(t, out, "c")
+
+	// completion emits the last element without waiting
+	src.send(t, "d")
+	src.complete()
+	expectElement(t, out, "d")
+	awaitResult(t, result)
+	expectNoElement(t, out)
+}
+
+func TestSampleManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	src, out, result := emitted(sys, goflow.Sample(time.Minute))
+	src.await(t)
+
+	src.send(t, "a", "b")
+	expectNoElement(t, out)
+	clock.Advance(time.Minute)
+	expectElement(t, out, "b")
+	// nothing is emitted for a period without elements
+	clock.Advance(time.Minute)
+	expectNoElement(t, out)
+
+	src.send(t, "c")
+	src.complete()
+	expectElement(t, out, "c")
+	awaitResult(t, result)
+}
+
+func TestGroupedWithinManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	src, out, result := emitted(sys, goflow.GroupedWithin(2, time.Minute))
+	src.await(t)
+
+	// full groups do not wait for the window
+	src.send(t, "a", "b")
+	expectElement(t, out, []interface{}{"a", "b"})
+	clock.Advance(time.Minute)
+	expectNoElement(t, out)
+
+	src.send(t, "c", "d", "e")
+	expectElement(t, out, []interface{}{"c", "d"})
+	src.complete()
+	expectElement(t, out, []interface{}{"e"})
+	awaitResult(t, result)
+}
+
+/* =================== */
+
+type panicking struct{}
+
+func (panicking) Handle(v interface{}) (interface{}, error) {
+	if v == "panic" {
+		panic("panicking behavior")
+	}
+	return v, nil
+}
+
+func TestRestartWithBackoffManualClock(t *testing.T) {
+	clock, sys := manualSystem()
+	handler := sys.NewBehaviorHandler(panicking{},
+		goflow.WithPoolSize(1),
+		goflow.WithSupervisor(goflow.RestartWithBackoff(time.Second, time.Minute)),
+	)
+	defer handler.Stop()
+	ref := handler.Ref()
+
+	if _, err := ref.Request("panic"); err == nil {
+		t.Fatal("expected the panic as error")
+	}
+	// the worker restarts after 1s and fails again, the next restart is
+	// backed off to 2s
+	awaitTimers(t, clock, 1)
+	reply := ref.RequestChan("panic")
+	clock.Advance(time.Second - time.Millisecond)
+	expectPending(t, reply)
+	clock.Advance(time.Millisecond)
+	if _, ok := awaitResult(t, reply).(error); !ok {
+		t.Fatal("expected the panic as error")
+	}
+
+	awaitTimers(t, clock, 1)
+	reply = ref.RequestChan("ok")
+	clock.Advance(2*time.Second - time.Millisecond)
+	expectPending(t, reply)
+	clock.Advance(time.Millisecond)
+	if v := awaitResult(t, reply); v != "ok" {
+		t.Errorf("expected ok, got %v", v)
+	}
+}
+
+func TestErrorsUseSystemClock(t *testing.T) {
+	_, sys := manualSystem()
+	handler := sys.NewBehaviorHandler(echo())
+	defer handler.Stop()
+
+	result := sys.Run(goflow.Sequence().To(goflow.BehaviorConsumer(handler.Ref(), "ack")))
+	v := awaitResult(t, result)
+	if failure, ok := v.(*goflow.Error); !ok || !failure.Timestamp.Equal(time.Unix(0, 0)) {
+		t.Errorf("expected the acknowledgement error stamped by the system clock, got %v", v)
+	}
+
+	panicked := sys.NewBehaviorHandler(panicking{})
+	defer panicked.Stop()
+	_, err := request(t, panicked.Ref(), "panic")
+	if failure, ok := err.(*goflow.Error); !ok || !failure.Timestamp.Equal(time.Unix(0, 0)) {
+		t.Errorf("expected the panic stamped by the system clock, got %v", err)
+	}
+}
+
+func TestGroupedWithinFlushesPartialGroup(t *testing.T) {
+	clock, sys := manualSystem()
+	src, out, result := emitted(sys, goflow.GroupedWithin(3, time.Minute))
+	src.await(t)
+
+	src.send(t, "a")
+	clock.Advance(59 * time.Second)
+	expectNoElement(t, out)
+	// the stage requests ahead, the elements arrive while nothing is
+	// emitted
+	src.send(t, "b")
+	expectNoElement(t, out)
+	// the window elapsed with two of three elements buffered
+	clock.Advance(2 * time.Second)
+	expectElement(t, out, []interface{}{"a", "b"})
+
+	// the next window starts with the emission at 1m, the clock is at
+	// 1m1s
+	src.send(t, "c")
+	expectNoElement(t, out)
+	clock.Advance(58 * time.Second)
+	expectNoElement(t, out)
+	clock.Advance(time.Second)
+	expectElement(t, out, []interface{}{"c"})
+
+	// empty windows emit nothing
+	clock.Advance(time.Minute)
+	expectNoElement(t, out)
+	src.complete()
+	awaitResult(t, result)
+	expectNoElement(t, out)
+}
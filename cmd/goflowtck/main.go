@@ -0,0 +1,26 @@
+// Command goflowtck runs the flowtest conformance suite against every
+// built-in goflow stage.
+//
+//	go run ./cmd/goflowtck
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ioswarm/goflow/flowtest"
+)
+
+func main() {
+	failed := 0
+	for _, result := range flowtest.Builtins() {
+		fmt.Println(result)
+		if !result.Passed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%v cases failed\n", failed)
+		os.Exit(1)
+	}
+}
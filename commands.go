@@ -14,12 +14,18 @@ func Done() DoneCommand { return DoneCommand{} }
 
 type CallCommand struct {
 	Data   interface{}
+	Sender Ref
 	result chan interface{}
 }
 
 func Call(data interface{}) CallCommand {
+	return CallFrom(data, nil)
+}
+
+func CallFrom(data interface{}, sender Ref) CallCommand {
 	return CallCommand{
 		Data:   data,
+		Sender: sender,
 		result: make(chan interface{}, 1),
 	}
 }
@@ -37,6 +43,12 @@ type PullCommand struct{}
 
 func Pull() PullCommand { return PullCommand{} }
 
+type RequestCommand struct {
+	N uint64
+}
+
+func Request(n uint64) RequestCommand { return RequestCommand{n} }
+
 type CancelCommand struct{}
 
 func Cancel() CancelCommand { return CancelCommand{} }
@@ -56,3 +68,9 @@ func ErrorCmd(err error) ErrorCommand { return ErrorCommand{err} }
 type CompleteCommand struct{}
 
 func Complete() CompleteCommand { return CompleteCommand{} }
+
+type FailureCommand struct {
+	Error *Error
+}
+
+func Failure(err *Error) FailureCommand { return FailureCommand{err} }
@@ -86,10 +86,160 @@ func (s *slice) OnComplete() interface{} {
 
 /* =================== */
 
+func BehaviorConsumer(ref Ref, ackCmd interface{}) RunnableConsumer {
+	return NewBehaviorConsumer(ref, nil, ackCmd, nil, nil)
+}
+
+// NewBehaviorConsumer requests every element from ref and pulls the next
+// one only after ref replied with ackCmd, a nil ackCmd accepts any reply.
+// initCmd is requested before the first element, completeCmd and the
+// message built by failureCmd are sent when the stream terminates.
+func NewBehaviorConsumer(ref Ref, initCmd interface{}, ackCmd interface{}, completeCmd interface{}, failureCmd func(error) interface{}) RunnableConsumer {
+	return &behaviorConsumer{
+		ref:         ref,
+		initCmd:     initCmd,
+		ackCmd:      ackCmd,
+		completeCmd: completeCmd,
+		failureCmd:  failureCmd,
+		result:      make(chan interface{}, 1),
+		completed:   make(chan struct{}),
+	}
+}
+
+type behaviorConsumer struct {
+	sync.Mutex
+	system      *system
+	inlet       Inlet
+	ref         Ref
+	initCmd     interface{}
+	ackCmd      interface{}
+	completeCmd interface{}
+	failureCmd  func(error) interface{}
+	result      chan interface{}
+	completed   chan struct{}
+	finished    bool
+}
+
+func (bc *behaviorConsumer) OnSubscribe(inlet Inlet) {
+	bc.Lock()
+	defer bc.Unlock()
+	bc.inlet = inlet
+}
+
+func (bc *behaviorConsumer) OnPush(v interface{}) {
+	if err := bc.request(v); err != nil {
+		bc.abort(err)
+		return
+	}
+	bc.inlet.Pull()
+}
+
+// abort cancels upstream and terminates like a failed stream, ref receives
+// the failure message as well
+func (bc *behaviorConsumer) abort(err error) {
+	bc.inlet.Cancel()
+	bc.OnError(err)
+}
+
+// request waits for the reply of ref and checks the acknowledgement
+func (bc *behaviorConsumer) request(v interface{}) error {
+	reply, err := bc.ref.Request(v)
+	if err != nil {
+		return err
+	}
+	if bc.ackCmd != nil && reply != bc.ackCmd {
+		return Errorf("Expected acknowledgement %v, got %v", bc.ackCmd, reply).WithTimestamp(bc.system.clock.Now())
+	}
+	return nil
+}
+
+func (bc *behaviorConsumer) OnError(err error) {
+	if bc.isFinished() {
+		return
+	}
+	if bc.failureCmd != nil {
+		bc.ref.Send(bc.failureCmd(err))
+	}
+	bc.complete(err)
+}
+
+func (bc *behaviorConsumer) OnComplete() {
+	if bc.isFinished() {
+		return
+	}
+	if bc.completeCmd != nil {
+		bc.ref.Send(bc.completeCmd)
+	}
+	bc.complete(Done())
+}
+
+func (bc *behaviorConsumer) isFinished() bool {
+	bc.Lock()
+	defer bc.Unlock()
+	return bc.finished
+}
+
+func (bc *behaviorConsumer) complete(v interface{}) {
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.finished {
+		return
+	}
+	bc.finished = true
+	bc.result <- v
+	close(bc.result)
+	close(bc.completed)
+}
+
+func (bc *behaviorConsumer) Run() <-chan interface{} {
+	return bc.runIn(defaultSystem())
+}
+
+func (bc *behaviorConsumer) runIn(system *system) <-chan interface{} {
+	bc.system = system
+	system.resources.register(bc)
+	if b, ok := bc.inlet.(systemBinder); ok {
+		b.bindSystem(system)
+	}
+	go func() {
+		if bc.initCmd != nil {
+			if err := bc.request(bc.initCmd); err != nil {
+				bc.abort(err)
+				return
+			}
+		}
+		bc.inlet.Pull()
+	}()
+	return bc.result
+}
+
+func (bc *behaviorConsumer) Close() {
+	bc.inlet.Cancel()
+}
+
+func (bc *behaviorConsumer) resourceName() string {
+	return fmt.Sprintf("graph(%p)", bc)
+}
+
+func (bc *behaviorConsumer) shutdown() {
+	bc.Close()
+}
+
+func (bc *behaviorConsumer) kill() {
+	bc.complete(ErrorGraphTerminated)
+}
+
+func (bc *behaviorConsumer) done() <-chan struct{} {
+	return bc.completed
+}
+
+/* =================== */
+
 func NewConsumer(receiver Receiver) RunnableConsumer {
 	return &consumer{
-		result:   make(chan interface{}, 1),
-		receiver: receiver,
+		result:    make(chan interface{}, 1),
+		completed: make(chan struct{}),
+		receiver:  receiver,
 	}
 }
 
@@ -97,11 +247,16 @@ func NewConsumerFunc(f func(interface{})) RunnableConsumer {
 	return NewConsumer(ReceiverFunc(f))
 }
 
+const consumerDemand uint64 = 16
+
 type consumer struct {
 	sync.Mutex
-	inlet    Inlet
-	result   chan interface{}
-	receiver Receiver
+	inlet        Inlet
+	outstanding  uint64
+	result       chan interface{}
+	completed    chan struct{}
+	completeOnce sync.Once
+	receiver     Receiver
 }
 
 func (c *consumer) OnSubscribe(inlet Inlet) {
@@ -113,20 +268,51 @@ func (c *consumer) OnSubscribe(inlet Inlet) {
 
 func (c *consumer) OnPush(v interface{}) {
 	c.receiver.OnPush(v)
-	c.inlet.Pull()
+	c.Lock()
+	defer c.Unlock()
+	if c.outstanding > 0 {
+		c.outstanding--
+	}
+	// replenish demand once half of the requested elements are consumed
+	if c.outstanding <= consumerDemand/2 {
+		c.request(consumerDemand - c.outstanding)
+	}
+}
+
+func (c *consumer) request(n uint64) {
+	c.outstanding += n
+	c.inlet.Request(n)
 }
 func (c *consumer) OnError(err error) {
 	c.receiver.OnError(err)
+	c.complete(err)
 }
 
 func (c *consumer) OnComplete() {
-	c.result <- c.receiver.OnComplete()
-	close(c.result)
+	c.complete(c.receiver.OnComplete())
+}
+
+func (c *consumer) complete(v interface{}) {
+	c.completeOnce.Do(func() {
+		c.result <- v
+		close(c.result)
+		close(c.completed)
+	})
 }
 
 func (c *consumer) Run() <-chan interface{} {
+	return c.runIn(defaultSystem())
+}
+
+func (c *consumer) runIn(system *system) <-chan interface{} {
 	// TODO is valid and everything is set
-	c.inlet.Pull()
+	system.resources.register(c)
+	if b, ok := c.inlet.(systemBinder); ok {
+		b.bindSystem(system)
+	}
+	c.Lock()
+	c.request(consumerDemand)
+	c.Unlock()
 	return c.result
 }
 
@@ -134,3 +320,19 @@ func (c *consumer) Close() {
 	c.inlet.Cancel()
 	//c.OnComplete()
 }
+
+func (c *consumer) resourceName() string {
+	return fmt.Sprintf("graph(%p)", c)
+}
+
+func (c *consumer) shutdown() {
+	c.Close()
+}
+
+func (c *consumer) kill() {
+	c.complete(ErrorGraphTerminated)
+}
+
+func (c *consumer) done() <-chan struct{} {
+	return c.completed
+}
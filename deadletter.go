@@ -0,0 +1,39 @@
+package goflow
+
+import (
+	"time"
+)
+
+// DeadLetter wraps a message that could not be delivered or was dropped,
+// together with its target and the reason.
+type DeadLetter struct {
+	Message   interface{}
+	Target    interface{}
+	Reason    error
+	Timestamp time.Time
+}
+
+func newDeadLetter(message interface{}, target interface{}, reason error, timestamp time.Time) DeadLetter {
+	return DeadLetter{
+		Message:   message,
+		Target:    target,
+		Reason:    reason,
+		Timestamp: timestamp.UTC(),
+	}
+}
+
+// DeadLetters returns the dead-letter topic of the default system.
+func DeadLetters() Topic {
+	return defaultSystem().DeadLetters()
+}
+
+func (sys *system) DeadLetters() Topic {
+	return sys.deadLetters
+}
+
+func (sys *system) deadLetter(message interface{}, target interface{}, reason error) {
+	if sys == nil {
+		return
+	}
+	sys.deadLetters.Publish(newDeadLetter(message, target, reason, sys.clock.Now()))
+}
@@ -7,6 +7,19 @@ import (
 
 var (
 	ErrorRouteHandleNotFound *Error = newError("RouteHandler not found", "RouteHandle not found in registrated RouteHandler list", "GF-0101")
+	ErrorBehaviorStopped     *Error = newError("Behavior stopped", "BehaviorHandler is stopped and does not accept calls anymore", "GF-0202")
+	ErrorActorNameInvalid    *Error = newError("Actor name invalid", "Actor names must not be empty or contain a path separator", "GF-0203")
+	ErrorActorNameExists     *Error = newError("Actor name exists", "An actor with the given name is already spawned under this parent", "GF-0204")
+	ErrorRefClosed           *Error = newError("Ref closed", "Ref is closed and does not accept messages anymore", "GF-0205")
+	ErrorGraphTerminated     *Error = newError("Graph terminated", "Graph did not complete before the system terminated", "GF-0302")
+	ErrorQueueFull           *Error = newError("Queue full", "Queue buffer is full, the element was not enqueued", "GF-0303")
+	ErrorQueueClosed         *Error = newError("Queue closed", "Queue is completed or failed and does not accept elements anymore", "GF-0304")
+	ErrorNoSubscribers       *Error = newError("No subscribers", "Topic has no subscribers the message could be delivered to", "GF-0306")
+	ErrorTopicClosed         *Error = newError("Topic closed", "Topic is closed and does not accept messages anymore", "GF-0307")
+	ErrorAskTimeout          *Error = newError("Ask timed out", "Ref did not reply to the element within the timeout", "GF-0308")
+	ErrorIdleTimeout         *Error = newError("Idle timeout", "No element passed the stage within the idle timeout", "GF-0309")
+	ErrorCompletionTimeout   *Error = newError("Completion timeout", "Stream did not complete within the completion timeout", "GF-0310")
+	ErrorUnsubscribed        *Error = newError("Unsubscribed", "Subscriber was removed before the message could be delivered", "GF-0311")
 )
 
 func newError(message string, desc string, code string) *Error {
@@ -53,6 +66,11 @@ func (e *Error) WithDescription(desc string) *Error {
 	return e
 }
 
+func (e *Error) WithTimestamp(timestamp time.Time) *Error {
+	e.Timestamp = timestamp.UTC()
+	return e
+}
+
 func (e *Error) AddMeta(name string, value interface{}) *Error {
 	if e.Meta == nil {
 		e.Meta = make(map[string]interface{})
@@ -68,3 +86,8 @@ func (e *Error) GetMeta(name string) (interface{}, bool) {
 	}
 	return nil, false
 }
+
+func terminationError(pending []string) *Error {
+	return newError("Termination timed out", "Resources did not finish within the termination timeout and were closed forcibly", "GF-0301").
+		AddMeta("pending", pending)
+}
@@ -0,0 +1,365 @@
+package goflow
+
+import "sync"
+
+// Merge emits the elements of all graphs as they become available.
+func Merge(graphs ...Graph) Graph {
+	return newFanIn(&mergeStrategy{}, graphs...)
+}
+
+// Concat emits all elements of a graph before it starts the next one.
+func Concat(graphs ...Graph) Graph {
+	return newFanIn(&concatStrategy{}, graphs...)
+}
+
+// Zip emits a []interface{} holding one element of every graph and
+// completes with the first completed graph.
+func Zip(graphs ...Graph) Graph {
+	return ZipWith(func(values []interface{}) interface{} {
+		return values
+	}, graphs...)
+}
+
+func ZipWith(f func([]interface{}) interface{}, graphs ...Graph) Graph {
+	return newFanIn(&zipStrategy{f: f}, graphs...)
+}
+
+// MergeSorted merges graphs which are sorted by less into one sorted graph.
+func MergeSorted(less func(interface{}, interface{}) bool, graphs ...Graph) Graph {
+	return newFanIn(&mergeSortedStrategy{less: less}, graphs...)
+}
+
+/* =================== */
+
+type fanInStrategy interface {
+	// want returns the number of elements input i should have buffered
+	// or requested
+	want(fi *fanIn, i int) uint64
+	// emit pushes elements downstream as long as possible
+	emit(fi *fanIn)
+	// finished reports whether the stage completes
+	finished(fi *fanIn) bool
+}
+
+type fanInInput struct {
+	fanIn       *fanIn
+	index       int
+	inlet       Inlet
+	outstanding uint64
+	queue       []interface{}
+	completed   bool
+}
+
+func (in *fanInInput) OnSubscribe(inlet Inlet) {
+	in.fanIn.Lock()
+	defer in.fanIn.Unlock()
+	in.inlet = inlet
+}
+
+func (in *fanInInput) OnPush(v interface{}) {
+	in.fanIn.inputPush(in, v)
+}
+
+func (in *fanInInput) OnError(err error) {
+	in.fanIn.inputError(in, err)
+}
+
+func (in *fanInInput) OnComplete() {
+	in.fanIn.inputComplete(in)
+}
+
+func (in *fanInInput) Run() <-chan interface{} {
+	return nil
+}
+
+func (in *fanInInput) Close() {
+	in.inlet.Cancel()
+}
+
+func (in *fanInInput) exhausted() bool {
+	return in.completed && len(in.queue) == 0
+}
+
+func (in *fanInInput) pop() interface{} {
+	v := in.queue[0]
+	in.queue[0] = nil
+	in.queue = in.queue[1:]
+	return v
+}
+
+func newFanIn(strategy fanInStrategy, graphs ...Graph) Graph {
+	fi := &fanIn{
+		strategy: strategy,
+		inputs:   make([]*fanInInput, len(graphs)),
+	}
+	for i := range graphs {
+		fi.inputs[i] = &fanInInput{
+			fanIn: fi,
+			index: i,
+		}
+	}
+	for i, g := range graphs {
+		g.To(fi.inputs[i])
+	}
+	p := newGraph(fi, newMailbox())
+	for _, g := range graphs {
+		if source, ok := g.(*pipe); ok {
+			p.sources = append(p.sources, source)
+		}
+	}
+	return p
+}
+
+type fanIn struct {
+	sync.Mutex
+	outlet   Outlet
+	strategy fanInStrategy
+	inputs   []*fanInInput
+	demand   uint64
+	done     bool
+}
+
+func (fi *fanIn) Subscribe(outlet Outlet) {
+	fi.Lock()
+	defer fi.Unlock()
+	fi.outlet = outlet
+}
+
+func (fi *fanIn) OnRequest(n uint64) {
+	fi.Lock()
+	defer fi.Unlock()
+	if fi.done {
+		return
+	}
+	fi.demand = addDemand(fi.demand, n)
+	fi.update()
+}
+
+func (fi *fanIn) OnCancel() {
+	fi.Lock()
+	defer fi.Unlock()
+	if fi.done {
+		return
+	}
+	fi.done = true
+	fi.cancelInputs()
+	fi.outlet.Complete()
+}
+
+func (fi *fanIn) inputPush(in *fanInInput, v interface{}) {
+	fi.Lock()
+	defer fi.Unlock()
+	if fi.done {
+		return
+	}
+	if in.outstanding > 0 {
+		in.outstanding--
+	}
+	in.queue = append(in.queue, v)
+	fi.update()
+}
+
+func (fi *fanIn) inputError(in *fanInInput, err error) {
+	fi.Lock()
+	defer fi.Unlock()
+	in.completed = true
+	if fi.done {
+		return
+	}
+	fi.done = true
+	fi.cancelInputs()
+	fi.outlet.Error(err)
+}
+
+func (fi *fanIn) inputComplete(in *fanInInput) {
+	fi.Lock()
+	defer fi.Unlock()
+	in.completed = true
+	in.outstanding = 0
+	if fi.done {
+		return
+	}
+	fi.update()
+}
+
+func (fi *fanIn) push(v interface{}) {
+	fi.demand--
+	fi.outlet.Push(v)
+}
+
+// update emits what is possible, completes the stage if finished and
+// requests the missing elements of every input
+func (fi *fanIn) update() {
+	fi.strategy.emit(fi)
+	if fi.strategy.finished(fi) {
+		fi.done = true
+		fi.cancelInputs()
+		fi.outlet.Complete()
+		return
+	}
+	for i, in := range fi.inputs {
+		if in.completed || in.inlet == nil {
+			continue
+		}
+		have := addDemand(in.outstanding, uint64(len(in.queue)))
+		if want := fi.strategy.want(fi, i); want > have {
+			in.outstanding += want - have
+			in.inlet.Request(want - have)
+		}
+	}
+}
+
+func (fi *fanIn) cancelInputs() {
+	for _, in := range fi.inputs {
+		if !in.completed && in.inlet != nil {
+			in.inlet.Cancel()
+		}
+	}
+}
+
+func (fi *fanIn) allExhausted() bool {
+	for _, in := range fi.inputs {
+		if !in.exhausted() {
+			return false
+		}
+	}
+	return true
+}
+
+/* =================== */
+
+type mergeStrategy struct {
+	next int
+}
+
+func (s *mergeStrategy) want(fi *fanIn, i int) uint64 {
+	return fi.demand
+}
+
+func (s *mergeStrategy) emit(fi *fanIn) {
+	for fi.demand > 0 {
+		found := false
+		for k := 0; k < len(fi.inputs); k++ {
+			in := fi.inputs[(s.next+k)%len(fi.inputs)]
+			if len(in.queue) > 0 {
+				s.next = (in.index + 1) % len(fi.inputs)
+				fi.push(in.pop())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+func (s *mergeStrategy) finished(fi *fanIn) bool {
+	return fi.allExhausted()
+}
+
+/* =================== */
+
+type concatStrategy struct {
+	current int
+}
+
+func (s *concatStrategy) want(fi *fanIn, i int) uint64 {
+	if i == s.current {
+		return fi.demand
+	}
+	return 0
+}
+
+func (s *concatStrategy) emit(fi *fanIn) {
+	for s.current < len(fi.inputs) {
+		in := fi.inputs[s.current]
+		for fi.demand > 0 && len(in.queue) > 0 {
+			fi.push(in.pop())
+		}
+		if !in.exhausted() {
+			return
+		}
+		s.current++
+	}
+}
+
+func (s *concatStrategy) finished(fi *fanIn) bool {
+	return s.current >= len(fi.inputs)
+}
+
+/* =================== */
+
+type zipStrategy struct {
+	f func([]interface{}) interface{}
+}
+
+func (s *zipStrategy) want(fi *fanIn, i int) uint64 {
+	return fi.demand
+}
+
+func (s *zipStrategy) emit(fi *fanIn) {
+	if len(fi.inputs) == 0 {
+		return
+	}
+	for fi.demand > 0 {
+		for _, in := range fi.inputs {
+			if len(in.queue) == 0 {
+				return
+			}
+		}
+		values := make([]interface{}, len(fi.inputs))
+		for i, in := range fi.inputs {
+			values[i] = in.pop()
+		}
+		fi.push(s.f(values))
+	}
+}
+
+func (s *zipStrategy) finished(fi *fanIn) bool {
+	for _, in := range fi.inputs {
+		if in.exhausted() {
+			return true
+		}
+	}
+	return len(fi.inputs) == 0
+}
+
+/* =================== */
+
+type mergeSortedStrategy struct {
+	less func(interface{}, interface{}) bool
+}
+
+func (s *mergeSortedStrategy) want(fi *fanIn, i int) uint64 {
+	if fi.demand > 0 && len(fi.inputs[i].queue) == 0 {
+		return 1
+	}
+	return 0
+}
+
+func (s *mergeSortedStrategy) emit(fi *fanIn) {
+	for fi.demand > 0 {
+		var min *fanInInput
+		for _, in := range fi.inputs {
+			if in.exhausted() {
+				continue
+			}
+			if len(in.queue) == 0 {
+				// the head of every open input is needed to pick the smallest
+				return
+			}
+			if min == nil || s.less(in.queue[0], min.queue[0]) {
+				min = in
+			}
+		}
+		if min == nil {
+			return
+		}
+		fi.push(min.pop())
+	}
+}
+
+func (s *mergeSortedStrategy) finished(fi *fanIn) bool {
+	return fi.allExhausted()
+}
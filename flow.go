@@ -2,6 +2,9 @@ package goflow
 
 import (
 	"io"
+	"math"
+	"runtime/debug"
+	"sort"
 	"sync"
 )
 
@@ -45,21 +48,107 @@ func Filter(f func(interface{}) bool) Flow {
 
 /* =================== */
 
-func NewFlow(task Task) Flow {
+// Decider chooses how a flow continues after its task failed or panicked.
+// ResumeDirective drops the element, RestartDirective closes and
+// reinitializes the task, all other directives stop the flow with the error.
+type Decider func(error) Directive
+
+type FlowOption func(*flowSettings)
+
+func WithDecider(decider Decider) FlowOption {
+	return func(s *flowSettings) {
+		if decider != nil {
+			s.decider = decider
+		}
+	}
+}
+
+// WithSystem sets the system whose dead-letter topic receives the elements
+// dropped by ResumeDirective, defaults to the system the graph runs in.
+func WithSystem(system FlowSystem) FlowOption {
+	return func(s *flowSettings) {
+		s.system = system
+	}
+}
+
+// WithClock sets the clock of time based stages, defaults to the clock of
+// the system.
+func WithClock(clock Clock) FlowOption {
+	return func(s *flowSettings) {
+		if clock != nil {
+			s.clock = clock
+		}
+	}
+}
+
+type flowSettings struct {
+	decider Decider
+	system  FlowSystem
+	clock   Clock
+}
+
+func newFlowSettings(options []FlowOption) flowSettings {
+	s := flowSettings{
+		decider: func(error) Directive {
+			return StopDirective
+		},
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+func (s *flowSettings) flowSystem() FlowSystem {
+	if s.system == nil {
+		return defaultSystem()
+	}
+	return s.system
+}
+
+func (s *flowSettings) bindSystem(sys *system) {
+	if s.system == nil {
+		s.system = sys
+	}
+}
+
+func (s *flowSettings) currentClock() Clock {
+	if s.clock == nil {
+		return s.flowSystem().Clock()
+	}
+	return s.clock
+}
+
+func (s *flowSettings) deadLetter(v interface{}, target interface{}, err error) {
+	s.flowSystem().DeadLetters().Publish(newDeadLetter(v, target, err, s.currentClock().Now()))
+}
+
+func NewFlow(task Task, options ...FlowOption) Flow {
 	return &flow{
-		task: task,
+		flowSettings: newFlowSettings(options),
+		task:         task,
 	}
 }
 
-func NewFlowFunc(f func(interface{}) (interface{}, error)) Flow {
-	return NewFlow(TaskFunc(f))
+func NewFlowFunc(f func(interface{}) (interface{}, error), options ...FlowOption) Flow {
+	return NewFlow(TaskFunc(f), options...)
 }
 
 type flow struct {
 	sync.Mutex
+	flowSettings
 	inlet  Inlet
 	outlet Outlet
 	task   Task
+	failed bool
+}
+
+// bindSystem also binds tasks like Retry which use the system
+func (f *flow) bindSystem(sys *system) {
+	f.flowSettings.bindSystem(sys)
+	if b, ok := f.task.(systemBinder); ok {
+		b.bindSystem(sys)
+	}
 }
 
 func (f *flow) OnSubscribe(inlet Inlet) {
@@ -69,24 +158,56 @@ func (f *flow) OnSubscribe(inlet Inlet) {
 }
 
 func (f *flow) OnPush(v interface{}) {
-	result, err := f.task.OnHandle(v)
+	if f.failed {
+		return
+	}
+	result, err := f.handle(v)
 	if err != nil {
 		if err == io.EOF {
 			f.inlet.Pull()
 			return
 		}
+		switch f.decider(err) {
+		case ResumeDirective:
+			f.deadLetter(v, f, err)
+			f.inlet.Pull()
+			return
+		case RestartDirective:
+			f.task.OnClose()
+			f.task.OnInit()
+			f.inlet.Pull()
+			return
+		}
+		// a failed task terminates the stage, upstream is cancelled and
+		// its completion is not forwarded anymore
+		f.failed = true
+		f.inlet.Cancel()
 		f.outlet.Error(err)
 		return
 	}
 	f.outlet.Push(result)
 }
 
+func (f *flow) handle(v interface{}) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = taskPanicError(r, debug.Stack()).WithTimestamp(f.currentClock().Now())
+		}
+	}()
+	return f.task.OnHandle(v)
+}
+
 func (f *flow) OnError(err error) {
+	if f.failed {
+		return
+	}
 	f.outlet.Error(err)
 }
 
 func (f *flow) OnComplete() {
-	f.outlet.Complete()
+	if !f.failed {
+		f.outlet.Complete()
+	}
 	f.task.OnClose()
 }
 
@@ -97,8 +218,8 @@ func (f *flow) Subscribe(outlet Outlet) {
 	f.task.OnInit()
 }
 
-func (f *flow) OnPull() {
-	f.inlet.Pull()
+func (f *flow) OnRequest(n uint64) {
+	f.inlet.Request(n)
 }
 
 func (f *flow) OnCancel() {
@@ -116,11 +237,13 @@ func Take(u uint64) Flow {
 
 type take struct {
 	sync.Mutex
-	inlet  Inlet
-	outlet Outlet
-	pos    uint64
-	step   uint64
-	end    uint64
+	inlet     Inlet
+	outlet    Outlet
+	pos       uint64
+	step      uint64
+	end       uint64
+	requested uint64
+	cancelled bool
 }
 
 func (t *take) OnSubscribe(inlet Inlet) {
@@ -132,8 +255,14 @@ func (t *take) OnSubscribe(inlet Inlet) {
 func (t *take) OnPush(v interface{}) {
 	t.Lock()
 	defer t.Unlock()
+	if t.pos >= t.end {
+		return
+	}
 	t.pos += t.step
 	t.outlet.Push(v)
+	if t.pos >= t.end {
+		t.cancel()
+	}
 }
 
 func (t *take) OnError(err error) {
@@ -150,42 +279,371 @@ func (t *take) Subscribe(outlet Outlet) {
 	t.outlet = outlet
 }
 
-func (t *take) OnPull() {
+func (t *take) OnRequest(n uint64) {
 	t.Lock()
 	defer t.Unlock()
-	if t.pos < t.end {
-		t.inlet.Pull()
+	if t.pos >= t.end {
+		t.cancel()
 		return
 	}
-	t.inlet.Cancel()
+	if remaining := t.end - t.requested; n > remaining {
+		n = remaining
+	}
+	t.requested += n
+	t.inlet.Request(n)
 }
 
 func (t *take) OnCancel() {
-	t.inlet.Cancel()
+	t.Lock()
+	defer t.Unlock()
+	t.cancel()
+}
+
+func (t *take) cancel() {
+	if !t.cancelled {
+		t.cancelled = true
+		t.inlet.Cancel()
+	}
+}
+
+/* =================== */
+
+// mulDemand saturates like addDemand
+func mulDemand(n uint64, size uint64) uint64 {
+	if size != 0 && n > math.MaxUint64/size {
+		return math.MaxUint64
+	}
+	return n * size
+}
+
+// Grouped emits the elements in slices of size elements, the last slice
+// may be smaller.
+func Grouped(size int) Flow {
+	if size < 1 {
+		size = 1
+	}
+	return &grouped{
+		size: size,
+	}
+}
+
+type grouped struct {
+	sync.Mutex
+	inlet     Inlet
+	outlet    Outlet
+	size      int
+	buffer    []interface{}
+	cancelled bool
+}
+
+func (g *grouped) OnSubscribe(inlet Inlet) {
+	g.Lock()
+	defer g.Unlock()
+	g.inlet = inlet
+}
+
+func (g *grouped) OnPush(v interface{}) {
+	g.Lock()
+	defer g.Unlock()
+	g.buffer = append(g.buffer, v)
+	if len(g.buffer) >= g.size {
+		g.outlet.Push(g.buffer)
+		g.buffer = nil
+	}
+}
+
+func (g *grouped) OnError(err error) {
+	g.outlet.Error(err)
+}
+
+func (g *grouped) OnComplete() {
+	g.Lock()
+	defer g.Unlock()
+	// a partial group was requested as well, so there is demand for it
+	if len(g.buffer) > 0 && !g.cancelled {
+		g.outlet.Push(g.buffer)
+		g.buffer = nil
+	}
+	g.outlet.Complete()
+}
+
+func (g *grouped) Subscribe(outlet Outlet) {
+	g.Lock()
+	defer g.Unlock()
+	g.outlet = outlet
+}
+
+func (g *grouped) OnRequest(n uint64) {
+	g.inlet.Request(mulDemand(n, uint64(g.size)))
+}
+
+func (g *grouped) OnCancel() {
+	g.Lock()
+	defer g.Unlock()
+	g.cancelled = true
+	g.inlet.Cancel()
+}
+
+/* =================== */
+
+// Sliding emits windows of size elements, every window starts step
+// elements after the previous one. If upstream completes, the elements not
+// emitted yet are emitted in a smaller window.
+func Sliding(size int, step int) Flow {
+	if size < 1 {
+		size = 1
+	}
+	if step < 1 {
+		step = 1
+	}
+	return &sliding{
+		size: size,
+		step: step,
+	}
+}
+
+type sliding struct {
+	sync.Mutex
+	inlet     Inlet
+	outlet    Outlet
+	size      int
+	step      int
+	window    []interface{}
+	fresh     int
+	skip      int
+	started   bool
+	cancelled bool
+}
+
+func (s *sliding) OnSubscribe(inlet Inlet) {
+	s.Lock()
+	defer s.Unlock()
+	s.inlet = inlet
+}
+
+func (s *sliding) OnPush(v interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if s.skip > 0 {
+		s.skip--
+		return
+	}
+	s.window = append(s.window, v)
+	s.fresh++
+	if len(s.window) < s.size {
+		return
+	}
+	s.outlet.Push(s.window)
+	s.fresh = 0
+	if s.step < s.size {
+		s.window = append([]interface{}(nil), s.window[s.step:]...)
+		return
+	}
+	s.window = nil
+	s.skip = s.step - s.size
+}
+
+func (s *sliding) OnError(err error) {
+	s.outlet.Error(err)
+}
+
+func (s *sliding) OnComplete() {
+	s.Lock()
+	defer s.Unlock()
+	if s.fresh > 0 && !s.cancelled {
+		s.outlet.Push(s.window)
+		s.window = nil
+	}
+	s.outlet.Complete()
+}
+
+func (s *sliding) Subscribe(outlet Outlet) {
+	s.Lock()
+	defer s.Unlock()
+	s.outlet = outlet
+}
+
+// OnRequest requests size elements for the first window and step elements
+// for every further window
+func (s *sliding) OnRequest(n uint64) {
+	s.Lock()
+	defer s.Unlock()
+	if n == 0 {
+		return
+	}
+	demand := mulDemand(n, uint64(s.step))
+	if !s.started {
+		s.started = true
+		demand = addDemand(mulDemand(n-1, uint64(s.step)), uint64(s.size))
+	}
+	s.inlet.Request(demand)
+}
+
+func (s *sliding) OnCancel() {
+	s.Lock()
+	defer s.Unlock()
+	s.cancelled = true
+	s.inlet.Cancel()
+}
+
+/* =================== */
+
+// Batch aggregates the elements while downstream is slower than upstream,
+// up to max elements per batch. seed starts a batch from its first element
+// and aggregate adds the following elements.
+func Batch(max int, seed func(interface{}) interface{}, aggregate func(interface{}, interface{}) interface{}) Flow {
+	if max < 1 {
+		max = 1
+	}
+	return &batch{
+		max:       max,
+		seed:      seed,
+		aggregate: aggregate,
+	}
+}
+
+type batch struct {
+	sync.Mutex
+	inlet        Inlet
+	outlet       Outlet
+	max          int
+	seed         func(interface{}) interface{}
+	aggregate    func(interface{}, interface{}) interface{}
+	current      interface{}
+	count        int
+	demand       uint64
+	requested    int
+	started      bool
+	upstreamDone bool
+	done         bool
+}
+
+func (b *batch) OnSubscribe(inlet Inlet) {
+	b.Lock()
+	defer b.Unlock()
+	b.inlet = inlet
+}
+
+func (b *batch) OnPush(v interface{}) {
+	b.Lock()
+	defer b.Unlock()
+	if b.done {
+		return
+	}
+	if b.requested > 0 {
+		b.requested--
+	}
+	if b.count == 0 {
+		b.current = b.seed(v)
+	} else {
+		b.current = b.aggregate(b.current, v)
+	}
+	b.count++
+	b.update()
+}
+
+func (b *batch) OnError(err error) {
+	b.Lock()
+	defer b.Unlock()
+	b.upstreamDone = true
+	if b.done {
+		return
+	}
+	b.done = true
+	b.outlet.Error(err)
+}
+
+func (b *batch) OnComplete() {
+	b.Lock()
+	defer b.Unlock()
+	b.upstreamDone = true
+	if b.done {
+		return
+	}
+	b.update()
+}
+
+func (b *batch) Subscribe(outlet Outlet) {
+	b.Lock()
+	defer b.Unlock()
+	b.outlet = outlet
+}
+
+func (b *batch) OnRequest(n uint64) {
+	b.Lock()
+	defer b.Unlock()
+	if b.done {
+		return
+	}
+	b.started = true
+	b.demand = addDemand(b.demand, n)
+	b.update()
+}
+
+func (b *batch) OnCancel() {
+	b.Lock()
+	defer b.Unlock()
+	if b.done {
+		return
+	}
+	b.done = true
+	if !b.upstreamDone {
+		b.inlet.Cancel()
+	}
+	b.outlet.Complete()
+}
+
+// update emits the current batch if there is demand and keeps upstream
+// busy as long as the batch is not full
+func (b *batch) update() {
+	if b.count > 0 && b.demand > 0 {
+		b.demand--
+		b.outlet.Push(b.current)
+		b.current, b.count = nil, 0
+	}
+	if b.upstreamDone {
+		if b.count == 0 {
+			b.done = true
+			b.outlet.Complete()
+		}
+		return
+	}
+	if b.started && b.count+b.requested < b.max {
+		n := b.max - b.count - b.requested
+		b.requested += n
+		b.inlet.Request(uint64(n))
+	}
 }
 
 /* =================== */
 
 type FanOut interface {
 	Consumer
+	Branch() Graph
 }
 
+// NewFanOut broadcasts every element to all attached branches and only
+// pulls upstream when every branch has demand.
 func NewFanOut() FanOut {
+	return NewFanOutQuorum(0)
+}
+
+// NewFanOutQuorum pulls upstream as soon as quorum branches have demand,
+// branches without demand miss the element. A quorum of 0 waits for all
+// attached branches.
+func NewFanOutQuorum(quorum int) FanOut {
 	return &fanOut{
-		inbound: make(chan interface{}), // TODO configure size
+		quorum: quorum,
 	}
 }
 
 type fanOut struct {
 	sync.Mutex
-	inbound chan interface{}
-	inlet   Inlet
-	sequence uint64
-	lastCmd interface{}
-}
-
-func (fo *fanOut) run() {
-
+	inlet     Inlet
+	quorum    int
+	branches  []*outletProducer
+	inflight  uint64
+	completed bool
 }
 
 func (fo *fanOut) OnSubscribe(inlet Inlet) {
@@ -194,21 +652,131 @@ func (fo *fanOut) OnSubscribe(inlet Inlet) {
 	fo.inlet = inlet
 }
 
+func (fo *fanOut) Branch() Graph {
+	fo.Lock()
+	defer fo.Unlock()
+	branch := newOutletProducer(fo)
+	fo.branches = append(fo.branches, branch)
+	return NewGraph(branch)
+}
+
 func (fo *fanOut) OnPush(v interface{}) {
-	fo.inbound <- Push(v)
+	fo.Lock()
+	defer fo.Unlock()
+	if fo.inflight > 0 {
+		fo.inflight--
+	}
+	for _, branch := range fo.branches {
+		if branch.attached && branch.demand > 0 {
+			branch.demand--
+			branch.Push(v)
+		}
+	}
+	fo.request()
 }
 
 func (fo *fanOut) OnError(err error) {
-	fo.inbound <- ErrorCmd(err)
+	fo.Lock()
+	defer fo.Unlock()
+	fo.completed = true
+	for _, branch := range fo.branches {
+		if branch.attached {
+			branch.attached = false
+			branch.Error(err)
+		}
+	}
 }
 
 func (fo *fanOut) OnComplete() {
-	fo.inbound <- Complete()
+	fo.Lock()
+	defer fo.Unlock()
+	fo.completed = true
+	for _, branch := range fo.branches {
+		if branch.attached {
+			branch.attached = false
+			branch.Complete()
+		}
+	}
+}
+
+func (fo *fanOut) branchRequest(branch *outletProducer, n uint64) {
+	fo.Lock()
+	defer fo.Unlock()
+	if !branch.attached {
+		return
+	}
+	branch.demand = addDemand(branch.demand, n)
+	fo.request()
+}
+
+func (fo *fanOut) branchCancel(branch *outletProducer) {
+	fo.Lock()
+	defer fo.Unlock()
+	if !branch.attached {
+		return
+	}
+	branch.attached = false
+	branch.Complete()
+	for _, b := range fo.branches {
+		if b.attached {
+			fo.request()
+			return
+		}
+	}
+	if !fo.completed {
+		fo.completed = true
+		fo.inlet.Cancel()
+	}
+}
+
+// request pulls as many elements upstream as the quorum of attached
+// branches is able to receive
+func (fo *fanOut) request() {
+	if fo.completed || fo.inlet == nil {
+		return
+	}
+	demands := make([]uint64, 0, len(fo.branches))
+	for _, branch := range fo.branches {
+		if branch.attached {
+			demands = append(demands, branch.demand)
+		}
+	}
+	if len(demands) == 0 {
+		return
+	}
+	required := len(demands)
+	if fo.quorum > 0 && fo.quorum < required {
+		required = fo.quorum
+	}
+	sort.Slice(demands, func(i, j int) bool { return demands[i] > demands[j] })
+	if want := demands[required-1]; want > fo.inflight {
+		fo.inlet.Request(want - fo.inflight)
+		fo.inflight = want
+	}
+}
+
+/* =================== */
+
+type branchOwner interface {
+	branchRequest(*outletProducer, uint64)
+	branchCancel(*outletProducer)
 }
 
+func newOutletProducer(owner branchOwner) *outletProducer {
+	return &outletProducer{
+		owner:    owner,
+		attached: true,
+	}
+}
+
+// outletProducer is one branch of a junction, demand and attached are
+// guarded by its owner.
 type outletProducer struct {
 	sync.Mutex
-	outlet Outlet
+	outlet   Outlet
+	owner    branchOwner
+	demand   uint64
+	attached bool
 }
 
 func (fop *outletProducer) Subscribe(outlet Outlet) {
@@ -217,12 +785,12 @@ func (fop *outletProducer) Subscribe(outlet Outlet) {
 	fop.outlet = outlet
 }
 
-func (fop *outletProducer) OnPull() {
-	
+func (fop *outletProducer) OnRequest(n uint64) {
+	fop.owner.branchRequest(fop, n)
 }
 
 func (fop *outletProducer) OnCancel() {
-
+	fop.owner.branchCancel(fop)
 }
 
 func (fop *outletProducer) Push(v interface{}) {
@@ -235,4 +803,4 @@ func (fop *outletProducer) Error(err error) {
 
 func (fop *outletProducer) Complete() {
 	fop.outlet.Complete()
-}
\ No newline at end of file
+}
@@ -0,0 +1,196 @@
+package flowtest
+
+import (
+	"io"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func uint64Input(i int) interface{} {
+	return uint64(i)
+}
+
+// failing emits n elements and fails afterwards
+func failing(n int) goflow.Graph {
+	i := 0
+	return goflow.NewProducerFunc(func() (interface{}, error) {
+		if i >= n {
+			return nil, errProbe
+		}
+		i++
+		return i, nil
+	})
+}
+
+func seedBatch(v interface{}) interface{} {
+	return []interface{}{v}
+}
+
+func appendBatch(batch interface{}, v interface{}) interface{} {
+	return append(batch.([]interface{}), v)
+}
+
+// verifyBatchConflation feeds Batch faster than a slow consumer takes the
+// batches, the elements must arrive aggregated, complete and in order
+func verifyBatchConflation() Result {
+	const elements, max = 60, 10
+	return runCase("Batch", "slow consumer", func(v *verification) {
+		var batches [][]interface{}
+		result := goflow.Sequence().Take(elements).
+			Via(goflow.Batch(max, seedBatch, appendBatch)).
+			To(goflow.ForEach(func(batch interface{}) {
+				time.Sleep(2 * time.Millisecond)
+				batches = append(batches, batch.([]interface{}))
+			})).Run()
+		if _, ok := receive(result); !ok {
+			v.violate(RuleComplete, "stream did not complete within %v", Timeout)
+			return
+		}
+		received := make([]interface{}, 0, elements)
+		conflated := false
+		for _, batch := range batches {
+			if len(batch) > max {
+				v.violate(RuleBatch, "batch of %v elements exceeds %v", len(batch), max)
+			}
+			conflated = conflated || len(batch) > 1
+			received = append(received, batch...)
+		}
+		if !conflated {
+			v.violate(RuleBatch, "slow consumer received %v single element batches", len(batches))
+		}
+		if len(received) != elements {
+			v.violate(RuleBatch, "expected %v elements, received %v", elements, len(received))
+			return
+		}
+		for i, e := range received {
+			if e != uint64(i) {
+				v.violate(RuleBatch, "element %v is %v, order or content changed", i, e)
+				return
+			}
+		}
+	})
+}
+
+// Builtins verifies every built-in stage of goflow.
+func Builtins() []Result {
+	results := make([]Result, 0)
+	for _, spec := range []ProducerSpec{
+		{Name: "Sequence", New: goflow.Sequence, Elements: Infinite},
+		{Name: "NewSequence", New: func() goflow.Graph { return goflow.NewSequence(10, 5) }, Elements: Infinite},
+		{Name: "Sequence.Take", New: func() goflow.Graph { return goflow.Sequence().Take(5) }, Elements: 5},
+		{Name: "Broadcast", New: func() goflow.Graph { return goflow.Sequence().Take(5).Broadcast(1)[0] }, Elements: 5},
+		{Name: "Balance", New: func() goflow.Graph { return goflow.Sequence().Take(5).Balance(1)[0] }, Elements: 5},
+		{Name: "Merge", New: func() goflow.Graph {
+			return goflow.Merge(goflow.Sequence().Take(3), goflow.Sequence().Take(4))
+		}, Elements: 7},
+		{Name: "Concat", New: func() goflow.Graph {
+			return goflow.Concat(goflow.Sequence().Take(3), goflow.Sequence().Take(4))
+		}, Elements: 7},
+		{Name: "Zip", New: func() goflow.Graph {
+			return goflow.Zip(goflow.Sequence().Take(3), goflow.Sequence())
+		}, Elements: 3},
+		{Name: "MergeSorted", New: func() goflow.Graph {
+			return goflow.MergeSorted(func(a, b interface{}) bool { return a.(uint64) < b.(uint64) },
+				goflow.NewSequence(0, 2).Take(3), goflow.NewSequence(1, 2).Take(3))
+		}, Elements: 6},
+		{Name: "FlowGraph", New: func() goflow.Graph {
+			return goflow.NewFlowGraph().
+				Map(func(v uint64) uint64 { return v * 3 }).
+				Take(4).
+				WithProducer(goflow.Sequence())
+		}, Elements: 4},
+		{Name: "ChanProducer", New: func() goflow.Graph {
+			in := make(chan interface{}, 10)
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			close(in)
+			return goflow.ChanProducer(in)
+		}, Elements: 10},
+		{Name: "NewProducerFunc", New: func() goflow.Graph {
+			i := 0
+			return goflow.NewProducerFunc(func() (interface{}, error) {
+				if i >= 3 {
+					return nil, io.EOF
+				}
+				i++
+				return i, nil
+			})
+		}, Elements: 3},
+		{Name: "Recover", New: func() goflow.Graph {
+			return failing(3).Recover(func(error) interface{} { return -1 })
+		}, Elements: 4},
+		{Name: "GroupedWithin", New: func() goflow.Graph {
+			return goflow.Sequence().Take(10).Via(goflow.GroupedWithin(3, time.Hour))
+		}, Elements: 4},
+		{Name: "RecoverWithRetries", New: func() goflow.Graph {
+			return failing(3).RecoverWithRetries(1, func(error) goflow.Graph { return goflow.Sequence().Take(3) })
+		}, Elements: 6},
+	} {
+		results = append(results, VerifyProducer(spec)...)
+	}
+
+	for _, spec := range []FlowSpec{
+		{Name: "Map", New: func() goflow.Flow {
+			return goflow.Map(func(v interface{}) interface{} { return v.(uint64) * 2 })
+		}, Input: uint64Input},
+		{Name: "Filter", New: func() goflow.Flow {
+			return goflow.Filter(func(v interface{}) bool { return v.(uint64)%2 == 0 })
+		}, Input: uint64Input},
+		{Name: "Take", New: func() goflow.Flow { return goflow.Take(3) }, Input: uint64Input},
+		{Name: "MapAsync", New: func() goflow.Flow {
+			return goflow.MapAsync(4, func(v interface{}) (interface{}, error) { return v.(uint64) * 2, nil })
+		}, Input: uint64Input},
+		{Name: "MapAsyncUnordered", New: func() goflow.Flow {
+			return goflow.MapAsyncUnordered(4, func(v interface{}) (interface{}, error) { return v.(uint64) * 2, nil })
+		}, Input: uint64Input},
+		{Name: "Delay", New: func() goflow.Flow { return goflow.Delay(time.Millisecond) }, Input: uint64Input},
+		{Name: "Throttle", New: func() goflow.Flow { return goflow.Throttle(1000, time.Second, 10) }, Input: uint64Input},
+		{Name: "Debounce", New: func() goflow.Flow { return goflow.Debounce(time.Millisecond) }, Input: uint64Input, Buffering: true},
+		{Name: "Sample", New: func() goflow.Flow { return goflow.Sample(time.Millisecond) }, Input: uint64Input, Buffering: true},
+		{Name: "IdleTimeout", New: func() goflow.Flow { return goflow.IdleTimeout(10 * Timeout) }, Input: uint64Input},
+		{Name: "CompletionTimeout", New: func() goflow.Flow { return goflow.CompletionTimeout(10 * Timeout) }, Input: uint64Input},
+		{Name: "Grouped", New: func() goflow.Flow { return goflow.Grouped(3) }, Input: uint64Input},
+		{Name: "Sliding", New: func() goflow.Flow { return goflow.Sliding(3, 2) }, Input: uint64Input},
+		{Name: "Batch", New: func() goflow.Flow {
+			return goflow.Batch(3, seedBatch, appendBatch)
+		}, Input: uint64Input, Buffering: true},
+		{Name: "Ask", New: func() goflow.Flow {
+			echo := goflow.NewBehaviorHandler(goflow.BehaviorFunc(func(v interface{}) (interface{}, error) { return v, nil }))
+			return goflow.Ask(echo.Ref(), 2, Timeout)
+		}, Input: uint64Input},
+	} {
+		results = append(results, VerifyFlow(spec)...)
+	}
+	results = append(results, verifyBatchConflation())
+
+	for _, spec := range []FanOutSpec{
+		{Name: "Broadcast(3)", New: func() []goflow.Graph {
+			return goflow.Sequence().Take(20).Broadcast(3)
+		}, Elements: 20, MinBranches: 3, MaxBranches: 3},
+		{Name: "BroadcastQuorum(3,2)", New: func() []goflow.Graph {
+			return goflow.Sequence().Take(20).BroadcastQuorum(3, 2)
+		}, Elements: 20, MinBranches: 2, MaxBranches: 3},
+		{Name: "Balance(3)", New: func() []goflow.Graph {
+			return goflow.Sequence().Take(20).Balance(3)
+		}, Elements: 20, MinBranches: 1, MaxBranches: 1},
+	} {
+		results = append(results, VerifyFanOut(spec)...)
+	}
+
+	for _, spec := range []ConsumerSpec{
+		{Name: "Slice", New: goflow.Slice, Input: uint64Input},
+		{Name: "ForEach", New: func() goflow.RunnableConsumer {
+			return goflow.ForEach(func(interface{}) {})
+		}, Input: uint64Input},
+		{Name: "Ignore", New: goflow.Ignore, Input: uint64Input},
+		{Name: "BehaviorConsumer", New: func() goflow.RunnableConsumer {
+			ack := goflow.NewBehaviorHandler(goflow.BehaviorFunc(func(interface{}) (interface{}, error) { return goflow.Done(), nil }))
+			return goflow.BehaviorConsumer(ack.Ref(), goflow.Done())
+		}, Input: uint64Input},
+	} {
+		results = append(results, VerifyConsumer(spec)...)
+	}
+	return results
+}
@@ -0,0 +1,11 @@
+package flowtest_test
+
+import (
+	"testing"
+
+	"github.com/ioswarm/goflow/flowtest"
+)
+
+func TestBuiltins(t *testing.T) {
+	flowtest.Check(t, flowtest.Builtins())
+}
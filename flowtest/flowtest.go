@@ -0,0 +1,143 @@
+// Package flowtest provides a conformance harness for goflow stages. Any
+// Producer, Flow or RunnableConsumer can be verified against the rules of
+// the Inlet/Outlet protocol:
+//
+//	results := flowtest.VerifyFlow(flowtest.FlowSpec{
+//		Name:  "Double",
+//		New:   func() goflow.Flow { return double() },
+//		Input: func(i int) interface{} { return uint64(i) },
+//	})
+//	flowtest.Check(t, results)
+package flowtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	// Timeout is the maximum time to wait for an expected signal.
+	Timeout = time.Second
+	// Quiet is the time to wait for the absence of unexpected signals.
+	Quiet = 50 * time.Millisecond
+)
+
+const (
+	RuleDemand        = "no push without request"
+	RuleTerminalOnce  = "completion or error exactly once"
+	RuleTerminal      = "no signals after completion or error"
+	RuleCancel        = "cancel stops emission"
+	RuleComplete      = "finite producers complete after their last element"
+	RuleCompletion    = "upstream completion is propagated"
+	RuleError         = "upstream errors are propagated and terminal"
+	RuleCancelUp      = "downstream cancel is propagated upstream"
+	RuleRequest       = "consumers request elements when run"
+	RuleResult        = "consumers deliver exactly one result on completion"
+	RuleResultOnError = "consumers deliver a result on error"
+	RuleBranches      = "elements reach the expected number of branches"
+	RuleBranchCancel  = "cancelling a branch does not stall the others"
+	RuleBatch         = "elements are aggregated while downstream is slow"
+)
+
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: %v", v.Rule, v.Message)
+}
+
+type Result struct {
+	Stage      string
+	Case       string
+	Violations []Violation
+}
+
+func (r Result) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+func (r Result) String() string {
+	if r.Passed() {
+		return fmt.Sprintf("PASS %v/%v", r.Stage, r.Case)
+	}
+	lines := make([]string, 0, len(r.Violations)+1)
+	lines = append(lines, fmt.Sprintf("FAIL %v/%v", r.Stage, r.Case))
+	for _, v := range r.Violations {
+		lines = append(lines, "    "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check reports every violation of the given results as test error.
+func Check(t testing.TB, results ...[]Result) {
+	t.Helper()
+	for _, rs := range results {
+		for _, r := range rs {
+			for _, v := range r.Violations {
+				t.Errorf("%v/%v: %v", r.Stage, r.Case, v)
+			}
+		}
+	}
+}
+
+/* =================== */
+
+type verification struct {
+	sync.Mutex
+	violations []Violation
+}
+
+func (v *verification) violate(rule string, format string, a ...interface{}) {
+	v.Lock()
+	defer v.Unlock()
+	v.violations = append(v.violations, Violation{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *verification) result(stage string, name string) Result {
+	v.Lock()
+	defer v.Unlock()
+	return Result{
+		Stage:      stage,
+		Case:       name,
+		Violations: append([]Violation{}, v.violations...),
+	}
+}
+
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func runCase(stage string, name string, f func(*verification)) Result {
+	v := &verification{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				v.violate("no panic", "stage panicked: %v", r)
+			}
+		}()
+		f(v)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * Timeout):
+		v.violate("no deadlock", "case did not finish within %v", 10*Timeout)
+	}
+	return v.result(stage, name)
+}
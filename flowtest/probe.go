@@ -0,0 +1,195 @@
+package flowtest
+
+import (
+	"sync"
+
+	"github.com/ioswarm/goflow"
+)
+
+// downstream is a consumer probe that records every signal it receives
+// and checks the rules that apply to its upstream.
+type downstream struct {
+	sync.Mutex
+	v         *verification
+	inlet     goflow.Inlet
+	requested uint64
+	pushed    uint64
+	completes int
+	errors    int
+	cancelled bool
+	result    chan interface{}
+}
+
+func newDownstream(v *verification) *downstream {
+	return &downstream{
+		v:      v,
+		result: make(chan interface{}, 1),
+	}
+}
+
+func (d *downstream) OnSubscribe(inlet goflow.Inlet) {
+	d.Lock()
+	defer d.Unlock()
+	d.inlet = inlet
+}
+
+func (d *downstream) terminated() bool {
+	return d.completes+d.errors > 0
+}
+
+func (d *downstream) OnPush(v interface{}) {
+	d.Lock()
+	defer d.Unlock()
+	if d.terminated() {
+		d.v.violate(RuleTerminal, "push of %v after termination", v)
+	}
+	d.pushed++
+	if d.pushed > d.requested {
+		d.v.violate(RuleDemand, "push #%v exceeds the %v requested elements", d.pushed, d.requested)
+	}
+}
+
+func (d *downstream) OnError(err error) {
+	d.Lock()
+	defer d.Unlock()
+	if d.terminated() {
+		d.v.violate(RuleTerminalOnce, "error %v after termination", err)
+		return
+	}
+	d.errors++
+	d.result <- err
+	close(d.result)
+}
+
+func (d *downstream) OnComplete() {
+	d.Lock()
+	defer d.Unlock()
+	if d.terminated() {
+		d.v.violate(RuleTerminalOnce, "completion after termination")
+		return
+	}
+	d.completes++
+	d.result <- goflow.Done()
+	close(d.result)
+}
+
+func (d *downstream) Run() <-chan interface{} {
+	return d.result
+}
+
+func (d *downstream) Close() {
+	d.cancel()
+}
+
+func (d *downstream) request(n uint64) {
+	d.Lock()
+	d.requested += n
+	inlet := d.inlet
+	d.Unlock()
+	inlet.Request(n)
+}
+
+func (d *downstream) cancel() {
+	d.Lock()
+	d.cancelled = true
+	inlet := d.inlet
+	d.Unlock()
+	inlet.Cancel()
+}
+
+func (d *downstream) counts() (pushed uint64, completes int, errors int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.pushed, d.completes, d.errors
+}
+
+func (d *downstream) isTerminated() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.terminated()
+}
+
+/* =================== */
+
+// upstream is a producer probe that pushes elements only on demand and
+// records requests and cancellation of its consumer.
+type upstream struct {
+	sync.Mutex
+	outlet    goflow.Outlet
+	demand    uint64
+	requests  int
+	cancelled bool
+	input     func(int) interface{}
+	next      int
+}
+
+func newUpstream(input func(int) interface{}) *upstream {
+	return &upstream{
+		input: input,
+	}
+}
+
+func (u *upstream) Subscribe(outlet goflow.Outlet) {
+	u.Lock()
+	defer u.Unlock()
+	u.outlet = outlet
+}
+
+func (u *upstream) OnRequest(n uint64) {
+	u.Lock()
+	defer u.Unlock()
+	u.demand += n
+	u.requests++
+}
+
+func (u *upstream) OnCancel() {
+	u.Lock()
+	defer u.Unlock()
+	u.cancelled = true
+}
+
+// feed pushes as many elements as demanded, but not more than max.
+func (u *upstream) feed(max int) int {
+	pushed := 0
+	for pushed < max {
+		u.Lock()
+		if u.demand == 0 || u.cancelled {
+			u.Unlock()
+			return pushed
+		}
+		u.demand--
+		v := u.input(u.next)
+		u.next++
+		outlet := u.outlet
+		u.Unlock()
+		outlet.Push(v)
+		pushed++
+	}
+	return pushed
+}
+
+func (u *upstream) hasDemand() bool {
+	u.Lock()
+	defer u.Unlock()
+	return u.demand > 0
+}
+
+func (u *upstream) isCancelled() bool {
+	u.Lock()
+	defer u.Unlock()
+	return u.cancelled
+}
+
+func (u *upstream) complete() {
+	u.Lock()
+	outlet := u.outlet
+	u.Unlock()
+	outlet.Complete()
+}
+
+func (u *upstream) error(err error) {
+	u.Lock()
+	outlet := u.outlet
+	u.Unlock()
+	outlet.Error(err)
+}
@@ -0,0 +1,347 @@
+package flowtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+// Infinite marks producers that never complete on their own.
+const Infinite = -1
+
+const RuleDelivery = "requested elements are delivered"
+
+type ProducerSpec struct {
+	Name string
+	New  func() goflow.Graph
+	// Elements is the number of elements a finite producer emits before it
+	// completes, or Infinite.
+	Elements int
+}
+
+type FlowSpec struct {
+	Name  string
+	New   func() goflow.Flow
+	Input func(int) interface{}
+	// Buffering marks stages that pull ahead of demand and deliver what they
+	// hold before completing, the completion case requests the remainder.
+	Buffering bool
+}
+
+type ConsumerSpec struct {
+	Name  string
+	New   func() goflow.RunnableConsumer
+	Input func(int) interface{}
+}
+
+// FanOutSpec describes a stage with several branches, like Broadcast or
+// Balance.
+type FanOutSpec struct {
+	Name string
+	// New attaches the stage to a producer of Elements distinct elements and
+	// returns its branches.
+	New      func() []goflow.Graph
+	Elements int
+	// MinBranches and MaxBranches bound the number of branches every element
+	// is delivered to.
+	MinBranches int
+	MaxBranches int
+}
+
+// bufferedRemainder is requested from buffering stages after completion
+const bufferedRemainder = 1 << 20
+
+var errProbe = goflow.NewError("flowtest probe error")
+
+/* =================== */
+
+func VerifyProducer(spec ProducerSpec) []Result {
+	return []Result{
+		runCase(spec.Name, "demand", func(v *verification) {
+			d := newDownstream(v)
+			spec.New().To(d)
+			for _, n := range []uint64{1, 3} {
+				d.request(n)
+				expected, _, _ := d.counts()
+				expected += n
+				if !eventually(func() bool {
+					pushed, _, _ := d.counts()
+					return pushed >= expected || d.isTerminated()
+				}) {
+					v.violate(RuleDelivery, "requested %v elements, received %v", expected, d.pushed)
+				}
+				time.Sleep(Quiet)
+			}
+			d.cancel()
+		}),
+		runCase(spec.Name, "cancel", func(v *verification) {
+			d := newDownstream(v)
+			spec.New().To(d)
+			d.request(1 << 20)
+			eventually(func() bool {
+				pushed, _, _ := d.counts()
+				return pushed > 0 || d.isTerminated()
+			})
+			d.cancel()
+			if !eventually(func() bool {
+				before, _, _ := d.counts()
+				time.Sleep(Quiet)
+				after, _, _ := d.counts()
+				return before == after
+			}) {
+				v.violate(RuleCancel, "elements are still pushed %v after cancel", Timeout)
+			}
+		}),
+		runCase(spec.Name, "complete", func(v *verification) {
+			if spec.Elements == Infinite {
+				return
+			}
+			d := newDownstream(v)
+			spec.New().To(d)
+			d.request(uint64(spec.Elements) + 1)
+			if !eventually(d.isTerminated) {
+				v.violate(RuleComplete, "producer did not complete within %v", Timeout)
+				d.cancel()
+				return
+			}
+			time.Sleep(Quiet)
+			pushed, completes, errors := d.counts()
+			if completes != 1 || errors != 0 {
+				v.violate(RuleComplete, "expected one completion, got %v completions and %v errors", completes, errors)
+			}
+			if pushed != uint64(spec.Elements) {
+				v.violate(RuleComplete, "expected %v elements, got %v", spec.Elements, pushed)
+			}
+		}),
+	}
+}
+
+/* =================== */
+
+func connectFlow(v *verification, spec FlowSpec) (*upstream, *downstream) {
+	u := newUpstream(spec.Input)
+	d := newDownstream(v)
+	goflow.NewGraph(u).Via(spec.New()).To(d)
+	return u, d
+}
+
+// drive feeds upstream demand until downstream received at least n elements
+// or terminated.
+func drive(u *upstream, d *downstream, n uint64) bool {
+	return eventually(func() bool {
+		u.feed(1)
+		pushed, _, _ := d.counts()
+		return pushed >= n || d.isTerminated()
+	})
+}
+
+func VerifyFlow(spec FlowSpec) []Result {
+	return []Result{
+		runCase(spec.Name, "demand", func(v *verification) {
+			u, d := connectFlow(v, spec)
+			for _, n := range []uint64{1, 2} {
+				d.request(n)
+				expected, _, _ := d.counts()
+				expected += n
+				if !drive(u, d, expected) {
+					v.violate(RuleDelivery, "requested %v elements, received %v", expected, d.pushed)
+				}
+				// keep feeding to reveal pushes beyond demand
+				deadline := time.Now().Add(Quiet)
+				for time.Now().Before(deadline) {
+					u.feed(1)
+					time.Sleep(time.Millisecond)
+				}
+			}
+			d.cancel()
+		}),
+		runCase(spec.Name, "completion", func(v *verification) {
+			u, d := connectFlow(v, spec)
+			d.request(1)
+			drive(u, d, 1)
+			u.complete()
+			if spec.Buffering {
+				d.request(bufferedRemainder)
+			}
+			if !eventually(d.isTerminated) {
+				v.violate(RuleCompletion, "downstream did not complete within %v", Timeout)
+				return
+			}
+			time.Sleep(Quiet)
+			if _, completes, errors := d.counts(); completes != 1 || errors != 0 {
+				v.violate(RuleCompletion, "expected one completion, got %v completions and %v errors", completes, errors)
+			}
+		}),
+		runCase(spec.Name, "error", func(v *verification) {
+			u, d := connectFlow(v, spec)
+			d.request(1)
+			u.error(errProbe)
+			if !eventually(d.isTerminated) {
+				v.violate(RuleError, "downstream did not receive the error within %v", Timeout)
+				return
+			}
+			time.Sleep(Quiet)
+			if _, completes, errors := d.counts(); errors != 1 || completes != 0 {
+				v.violate(RuleError, "expected one error, got %v errors and %v completions", errors, completes)
+			}
+		}),
+		runCase(spec.Name, "cancel", func(v *verification) {
+			u, d := connectFlow(v, spec)
+			d.request(1)
+			d.cancel()
+			if !eventually(u.isCancelled) {
+				v.violate(RuleCancelUp, "upstream was not cancelled within %v", Timeout)
+			}
+			u.complete()
+			if !eventually(d.isTerminated) {
+				v.violate(RuleCompletion, "downstream did not complete after cancel within %v", Timeout)
+			}
+		}),
+	}
+}
+
+/* =================== */
+
+func receive(result <-chan interface{}) (interface{}, bool) {
+	select {
+	case v, open := <-result:
+		return v, open
+	case <-time.After(Timeout):
+		return nil, false
+	}
+}
+
+func VerifyConsumer(spec ConsumerSpec) []Result {
+	return []Result{
+		runCase(spec.Name, "complete", func(v *verification) {
+			u := newUpstream(spec.Input)
+			result := goflow.NewGraph(u).To(spec.New()).Run()
+			if !eventually(u.hasDemand) {
+				v.violate(RuleRequest, "consumer did not request within %v", Timeout)
+				return
+			}
+			for fed := 0; fed < 5; {
+				if !eventually(u.hasDemand) {
+					v.violate(RuleRequest, "consumer stopped requesting after %v elements", fed)
+					return
+				}
+				fed += u.feed(5 - fed)
+			}
+			u.complete()
+			if _, ok := receive(result); !ok {
+				v.violate(RuleResult, "no result within %v after completion", Timeout)
+				return
+			}
+			if _, open := receive(result); open {
+				v.violate(RuleResult, "result channel delivered more than one result")
+			}
+		}),
+		runCase(spec.Name, "error", func(v *verification) {
+			u := newUpstream(spec.Input)
+			result := goflow.NewGraph(u).To(spec.New()).Run()
+			eventually(u.hasDemand)
+			u.feed(1)
+			u.error(errProbe)
+			if _, ok := receive(result); !ok {
+				v.violate(RuleResultOnError, "no result within %v after error", Timeout)
+			}
+		}),
+	}
+}
+
+/* =================== */
+
+// branchProbe records the elements one branch of a fan-out received
+type branchProbe struct {
+	sync.Mutex
+	received []interface{}
+	result   <-chan interface{}
+	runnable goflow.Runnable
+}
+
+func runBranch(g goflow.Graph) *branchProbe {
+	p := &branchProbe{}
+	p.runnable = g.To(goflow.ForEach(func(e interface{}) {
+		p.Lock()
+		defer p.Unlock()
+		p.received = append(p.received, e)
+	}))
+	p.result = p.runnable.Run()
+	return p
+}
+
+func (p *branchProbe) elements() []interface{} {
+	p.Lock()
+	defer p.Unlock()
+	return append([]interface{}{}, p.received...)
+}
+
+// reach counts the branches every element was delivered to, duplicates
+// within a branch are reported
+func reach(v *verification, probes []*branchProbe) map[interface{}]int {
+	counts := make(map[interface{}]int)
+	for i, p := range probes {
+		seen := make(map[interface{}]bool)
+		for _, e := range p.elements() {
+			if seen[e] {
+				v.violate(RuleBranches, "branch %v received %v more than once", i, e)
+			}
+			seen[e] = true
+			counts[e]++
+		}
+	}
+	return counts
+}
+
+// checkReach reports elements delivered to fewer than min or more than max
+// branches
+func checkReach(v *verification, counts map[interface{}]int, elements int, min int, max int) {
+	if min > 0 && len(counts) != elements {
+		v.violate(RuleBranches, "expected %v distinct elements, the branches received %v", elements, len(counts))
+	}
+	for e, n := range counts {
+		if n < min || n > max {
+			v.violate(RuleBranches, "element %v reached %v branches, expected %v to %v", e, n, min, max)
+		}
+	}
+}
+
+func VerifyFanOut(spec FanOutSpec) []Result {
+	return []Result{
+		runCase(spec.Name, "delivery", func(v *verification) {
+			branches := spec.New()
+			probes := make([]*branchProbe, len(branches))
+			for i, branch := range branches {
+				probes[i] = runBranch(branch)
+			}
+			for i, p := range probes {
+				if _, ok := receive(p.result); !ok {
+					v.violate(RuleComplete, "branch %v did not complete within %v", i, Timeout)
+					return
+				}
+			}
+			checkReach(v, reach(v, probes), spec.Elements, spec.MinBranches, spec.MaxBranches)
+		}),
+		runCase(spec.Name, "cancel branch", func(v *verification) {
+			branches := spec.New()
+			probes := make([]*branchProbe, len(branches))
+			for i, branch := range branches {
+				probes[i] = runBranch(branch)
+			}
+			probes[0].runnable.Close()
+			for i, p := range probes[1:] {
+				if _, ok := receive(p.result); !ok {
+					v.violate(RuleBranchCancel, "branch %v did not complete within %v after branch 0 was cancelled", i+1, Timeout)
+					return
+				}
+			}
+			// elements pushed before the cancel still count for branch 0
+			min := spec.MinBranches
+			if min > len(branches)-1 {
+				min = len(branches) - 1
+			}
+			checkReach(v, reach(v, probes), spec.Elements, min, spec.MaxBranches)
+		}),
+	}
+}
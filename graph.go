@@ -10,50 +10,120 @@ type Graph interface {
 	Map(interface{}) Graph
 	Filter(interface{}) Graph
 
+	Recover(func(error) interface{}) Graph
+	RecoverWithRetries(int, func(error) Graph) Graph
+
 	Via(Flow) Graph
 	To(RunnableConsumer) Runnable
+	Broadcast(int) []Graph
+	BroadcastQuorum(int, int) []Graph
+	Balance(int) []Graph
 }
 
 /* =================== */
 
 type ConsumerGraph interface {
-	Graph
-	WithProducer(Producer)
+	WithProducer(Graph) Runnable
 }
 
 /* =================== */
 
 type FlowGraph interface {
-	ConsumerGraph
-	WithConsumer(Consumer)
+	Take(uint64) FlowGraph
+	Map(interface{}) FlowGraph
+	Filter(interface{}) FlowGraph
+	Recover(func(error) interface{}) FlowGraph
+	RecoverWithRetries(int, func(error) Graph) FlowGraph
+	Via(func() Flow) FlowGraph
+	Append(FlowGraph) FlowGraph
+
+	WithProducer(Graph) Graph
+	WithConsumer(func() RunnableConsumer) ConsumerGraph
+}
+
+// NewFlowGraph starts a reusable fragment without producer and consumer.
+// Every attachment materializes fresh stages.
+func NewFlowGraph() FlowGraph {
+	return &flowGraph{}
 }
 
-/*
 type flowGraph struct {
-	sync.Mutex
-	inPipe  pipe
-	outPipe pipe
+	stages []func(Graph) Graph
 }
 
-func (fg *flowGraph) WithProducer(Producer) {
-	fg.Lock()
-	defer fg.Unlock()
+func (fg *flowGraph) with(stages ...func(Graph) Graph) FlowGraph {
+	result := make([]func(Graph) Graph, 0, len(fg.stages)+len(stages))
+	result = append(result, fg.stages...)
+	return &flowGraph{
+		stages: append(result, stages...),
+	}
+}
 
+func (fg *flowGraph) Take(n uint64) FlowGraph {
+	return fg.with(func(g Graph) Graph {
+		return g.Take(n)
+	})
 }
 
-func (fg *flowGraph) WithConsumer(Consumer) {
-	fg.Lock()
-	defer fg.Unlock()
+func (fg *flowGraph) Map(f interface{}) FlowGraph {
+	mapFunc := variadicMapFunc(f)
+	return fg.Via(func() Flow {
+		return NewFlowFunc(mapFunc)
+	})
+}
 
+func (fg *flowGraph) Filter(f interface{}) FlowGraph {
+	filterFunc := variadicFilterFunc(f)
+	return fg.Via(func() Flow {
+		return NewFlowFunc(filterFunc)
+	})
 }
 
-func (fg *flowGraph) Via(flow Flow) Graph {
+func (fg *flowGraph) Recover(f func(error) interface{}) FlowGraph {
+	return fg.with(func(g Graph) Graph {
+		return g.Recover(f)
+	})
+}
+
+func (fg *flowGraph) RecoverWithRetries(n int, fallback func(error) Graph) FlowGraph {
+	return fg.with(func(g Graph) Graph {
+		return g.RecoverWithRetries(n, fallback)
+	})
+}
 
-	return fg
+func (fg *flowGraph) Via(factory func() Flow) FlowGraph {
+	return fg.with(func(g Graph) Graph {
+		return g.Via(factory())
+	})
 }
 
-func (fg *flowGraph) To(consumer RunnableConsumer) Runnable {
+func (fg *flowGraph) Append(other FlowGraph) FlowGraph {
+	return fg.with(func(g Graph) Graph {
+		return other.WithProducer(g)
+	})
+}
+
+func (fg *flowGraph) WithProducer(g Graph) Graph {
+	for _, stage := range fg.stages {
+		g = stage(g)
+	}
+	return g
+}
+
+func (fg *flowGraph) WithConsumer(factory func() RunnableConsumer) ConsumerGraph {
+	return &consumerGraph{
+		flowGraph: fg,
+		factory:   factory,
+	}
+}
+
+/* =================== */
+
+type consumerGraph struct {
+	flowGraph FlowGraph
+	factory   func() RunnableConsumer
+}
 
-	return nil
+func (cg *consumerGraph) WithProducer(g Graph) Runnable {
+	return cg.flowGraph.WithProducer(g).To(cg.factory())
 }
-*/
@@ -0,0 +1,68 @@
+package goflow_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ioswarm/goflow"
+)
+
+// fragment keeps state in Take, every attachment has to get its own
+func fragment() goflow.FlowGraph {
+	return goflow.NewFlowGraph().
+		Map(func(v uint64) uint64 { return v * 10 }).
+		Filter(func(v uint64) bool { return v%20 == 0 }).
+		Via(func() goflow.Flow { return goflow.Take(2) })
+}
+
+// runConcurrently starts one run per producer at the same time and
+// collects their results in producer order
+func runConcurrently(t *testing.T, run func(goflow.Graph) <-chan interface{}, producers ...goflow.Graph) []interface{} {
+	t.Helper()
+	results := make([]interface{}, len(producers))
+	var wg sync.WaitGroup
+	for i, producer := range producers {
+		wg.Add(1)
+		go func(i int, producer goflow.Graph) {
+			defer wg.Done()
+			results[i] = <-run(producer)
+		}(i, producer)
+	}
+	wg.Wait()
+	return results
+}
+
+func TestFlowGraphConcurrentAttachments(t *testing.T) {
+	flow := fragment()
+	results := runConcurrently(t, func(producer goflow.Graph) <-chan interface{} {
+		return flow.WithProducer(producer).To(goflow.Slice()).Run()
+	}, goflow.Sequence(), goflow.NewSequence(100, 1))
+
+	expected := []interface{}{
+		[]interface{}{uint64(0), uint64(20)},
+		[]interface{}{uint64(1000), uint64(1020)},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+}
+
+func TestConsumerGraphConcurrentAttachments(t *testing.T) {
+	consumer := goflow.NewFlowGraph().
+		Map(func(v uint64) uint64 { return v * 10 }).
+		Take(3).
+		WithConsumer(goflow.Slice)
+	results := runConcurrently(t, func(producer goflow.Graph) <-chan interface{} {
+		return consumer.WithProducer(producer).Run()
+	}, goflow.Sequence(), goflow.NewSequence(100, 1), goflow.NewSequence(5, 5))
+
+	expected := []interface{}{
+		[]interface{}{uint64(0), uint64(10), uint64(20)},
+		[]interface{}{uint64(1000), uint64(1010), uint64(1020)},
+		[]interface{}{uint64(50), uint64(100), uint64(150)},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+}
@@ -0,0 +1,126 @@
+package goflow
+
+import (
+	"sort"
+	"strings"
+)
+
+const pathSeparator = "/"
+
+func validActorName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, pathSeparator)
+}
+
+func (b *behaviorHandler) Name() string {
+	return b.name
+}
+
+func (b *behaviorHandler) Path() string {
+	if b.anonymous {
+		return ""
+	}
+	if b.owner == nil {
+		return pathSeparator + b.name
+	}
+	parentPath := b.owner.Path()
+	if parentPath == pathSeparator {
+		return parentPath + b.name
+	}
+	return parentPath + pathSeparator + b.name
+}
+
+func (b *behaviorHandler) Spawn(name string, behavior Behavior, options ...BehaviorOption) (BehaviorHandler, error) {
+	return b.spawn(name, behavior, nil, options...)
+}
+
+func (b *behaviorHandler) SpawnStateful(name string, behavior StatefulBehavior, options ...BehaviorOption) (BehaviorHandler, error) {
+	return b.spawn(name, nil, newActor(behavior), options...)
+}
+
+func (b *behaviorHandler) spawn(name string, behavior Behavior, actor *actor, options ...BehaviorOption) (*behaviorHandler, error) {
+	if !validActorName(name) {
+		return nil, ErrorActorNameInvalid
+	}
+	b.Lock()
+	defer b.Unlock()
+	select {
+	case <-b.stopped:
+		return nil, ErrorBehaviorStopped
+	default:
+	}
+	if _, exists := b.children[name]; exists {
+		return nil, ErrorActorNameExists
+	}
+	parent := b.self()
+	child := newBehaviorHandler(behavior, actor, append([]BehaviorOption{
+		WithParent(parent),
+		func(bh *behaviorHandler) {
+			bh.name = name
+			bh.owner = b
+			bh.system = b.system
+		},
+	}, options...)...)
+	b.children[name] = child
+	return child, nil
+}
+
+func (b *behaviorHandler) Child(name string) (BehaviorHandler, bool) {
+	if child, ok := b.child(name); ok {
+		return child, true
+	}
+	return nil, false
+}
+
+func (b *behaviorHandler) child(name string) (*behaviorHandler, bool) {
+	b.RLock()
+	defer b.RUnlock()
+	child, ok := b.children[name]
+	return child, ok
+}
+
+func (b *behaviorHandler) Children() []BehaviorHandler {
+	handlers := b.childHandlers()
+	result := make([]BehaviorHandler, 0, len(handlers))
+	for _, child := range handlers {
+		result = append(result, child)
+	}
+	return result
+}
+
+func (b *behaviorHandler) childHandlers() []*behaviorHandler {
+	b.RLock()
+	defer b.RUnlock()
+	names := make([]string, 0, len(b.children))
+	for name := range b.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*behaviorHandler, 0, len(names))
+	for _, name := range names {
+		result = append(result, b.children[name])
+	}
+	return result
+}
+
+func (b *behaviorHandler) removeChild(child *behaviorHandler) {
+	b.Lock()
+	defer b.Unlock()
+	if current, ok := b.children[child.name]; ok && current == child {
+		delete(b.children, child.name)
+	}
+}
+
+func (b *behaviorHandler) lookup(path string) (*behaviorHandler, bool) {
+	current := b
+	for _, name := range strings.Split(strings.Trim(path, pathSeparator), pathSeparator) {
+		if name == "" {
+			continue
+		}
+		child, ok := current.child(name)
+		if !ok {
+			return nil, false
+		}
+		current = child
+	}
+	return current, true
+}
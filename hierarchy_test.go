@@ -0,0 +1,141 @@
+package goflow_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func spawn(t *testing.T, parent interface {
+	Spawn(string, goflow.Behavior, ...goflow.BehaviorOption) (goflow.BehaviorHandler, error)
+}, name string) goflow.BehaviorHandler {
+	t.Helper()
+	handler, err := parent.Spawn(name, echo())
+	if err != nil {
+		t.Fatalf("spawn %v failed with %v", name, err)
+	}
+	return handler
+}
+
+func awaitTerminated(t *testing.T, handler goflow.BehaviorHandler) {
+	t.Helper()
+	select {
+	case <-handler.Terminated():
+	case <-time.After(time.Second):
+		t.Fatalf("%v did not terminate", handler.Path())
+	}
+}
+
+func TestActorPaths(t *testing.T) {
+	sys := goflow.NewSystem()
+	parent := spawn(t, sys, "parent")
+	b := spawn(t, parent, "b")
+	a := spawn(t, parent, "a")
+	grandchild := spawn(t, a, "grandchild")
+
+	if parent.Path() != "/user/parent" || grandchild.Path() != "/user/parent/a/grandchild" {
+		t.Errorf("unexpected paths %v, %v", parent.Path(), grandchild.Path())
+	}
+	if grandchild.Name() != "grandchild" {
+		t.Errorf("unexpected name %v", grandchild.Name())
+	}
+	if child, ok := parent.Child("a"); !ok || child != a {
+		t.Errorf("expected child a, got %v", child)
+	}
+	if children := parent.Children(); len(children) != 2 || children[0] != a || children[1] != b {
+		t.Errorf("expected children sorted by name, got %v", children)
+	}
+	if sys.NewBehaviorHandler(echo()).Path() != "" {
+		t.Error("anonymous handlers have no path")
+	}
+
+	ref, ok := sys.Lookup("/user/parent/a/grandchild")
+	if !ok {
+		t.Fatal("grandchild not found")
+	}
+	if v, err := request(t, ref, "hello"); v != "hello" || err != nil {
+		t.Errorf("expected hello, got %v, %v", v, err)
+	}
+	if _, ok := sys.Lookup("/user/parent/c"); ok {
+		t.Error("found an unknown path")
+	}
+}
+
+func TestSpawnNames(t *testing.T) {
+	sys := goflow.NewSystem()
+	for _, name := range []string{"", ".", "..", "a/b"} {
+		if _, err := sys.Spawn(name, echo()); err != goflow.ErrorActorNameInvalid {
+			t.Errorf("expected %v for %q, got %v", goflow.ErrorActorNameInvalid, name, err)
+		}
+	}
+
+	parent := spawn(t, sys, "parent")
+	if _, err := sys.Spawn("parent", echo()); err != goflow.ErrorActorNameExists {
+		t.Errorf("expected %v, got %v", goflow.ErrorActorNameExists, err)
+	}
+	// names are unique per parent only
+	spawn(t, parent, "parent")
+
+	parent.Stop()
+	if _, err := parent.Spawn("late", echo()); err != goflow.ErrorBehaviorStopped {
+		t.Errorf("expected %v, got %v", goflow.ErrorBehaviorStopped, err)
+	}
+}
+
+func TestStopCommandStopsChildren(t *testing.T) {
+	sys := goflow.NewSystem()
+	parent := spawn(t, sys, "parent")
+	child := spawn(t, parent, "child")
+	grandchild := spawn(t, child, "grandchild")
+
+	parent.Ref().IN() <- goflow.Stop()
+	for _, handler := range []goflow.BehaviorHandler{grandchild, child, parent} {
+		awaitTerminated(t, handler)
+	}
+	for _, path := range []string{"/user/parent", "/user/parent/child", "/user/parent/child/grandchild"} {
+		if _, ok := sys.Lookup(path); ok {
+			t.Errorf("%v is still registered", path)
+		}
+	}
+}
+
+func TestStopRemovesChild(t *testing.T) {
+	sys := goflow.NewSystem()
+	parent := spawn(t, sys, "parent")
+	child := spawn(t, parent, "child")
+
+	child.Stop()
+	awaitTerminated(t, child)
+	if _, ok := parent.Child("child"); ok {
+		t.Error("stopped child is still registered")
+	}
+	if len(parent.Children()) != 0 {
+		t.Errorf("unexpected children %v", parent.Children())
+	}
+	// the name is free again
+	spawn(t, parent, "child")
+}
+
+func TestLookupSharesRef(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := spawn(t, sys, "shared")
+	first, _ := sys.Lookup("/user/shared")
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		if ref, _ := sys.Lookup("/user/shared"); ref != first {
+			t.Fatal("expected the same ref for every lookup")
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before+10 {
+		t.Errorf("lookups started %v goroutines", after-before)
+	}
+
+	handler.Stop()
+	awaitTerminated(t, handler)
+	if _, err := request(t, first, "late"); err == nil {
+		t.Error("expected the shared ref to reject calls after stop")
+	}
+}
@@ -1,6 +1,15 @@
 package goflow
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
 
 type LogType int
 
@@ -30,18 +39,263 @@ type Logger interface {
 	ERROR(msg string, a ...interface{})
 	FATAL(msg string, a ...interface{})
 	PANIC(msg string, a ...interface{})
+
+	WithField(name string, value interface{}) Logger
+	WithFields(params ...Parameter) Logger
 }
 
 type LogEntry struct {
 	LogType   LogType
 	Timestamp time.Time
 	Message   string
+	Fields    Parameters
 }
 
 func (le *LogEntry) ToMap() map[string]interface{} {
-	return map[string]interface{}{
-		"logType": LogTypeName(int(le.LogType)),
+	result := map[string]interface{}{
+		"logType":   LogTypeName(int(le.LogType)),
 		"timestamp": le.Timestamp.UTC().Format("2006-01-02T15:04:05.999Z"),
-		"message": le.Message,
+		"message":   le.Message,
+	}
+	if len(le.Fields) > 0 {
+		result["fields"] = map[string]interface{}(le.Fields)
+	}
+	return result
+}
+
+func (le *LogEntry) String() string {
+	var sb strings.Builder
+	sb.WriteString(le.Timestamp.UTC().Format("2006-01-02T15:04:05.999Z"))
+	sb.WriteString(" [")
+	sb.WriteString(LogTypeName(int(le.LogType)))
+	sb.WriteString("] ")
+	sb.WriteString(le.Message)
+	names := make([]string, 0, len(le.Fields))
+	for name := range le.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, " %v=%v", name, le.Fields[name])
+	}
+	return sb.String()
+}
+
+/* =================== */
+
+type LogSink interface {
+	Write(LogEntry)
+}
+
+type LogSinkFunc func(LogEntry)
+
+func (f LogSinkFunc) Write(entry LogEntry) {
+	f(entry)
+}
+
+/* =================== */
+
+func NewTextSink(w io.Writer) LogSink {
+	var mu sync.Mutex
+	return LogSinkFunc(func(entry LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(w, entry.String())
+	})
+}
+
+func StderrSink() LogSink {
+	return NewTextSink(os.Stderr)
+}
+
+/* =================== */
+
+func NewJSONSink(w io.Writer) LogSink {
+	var mu sync.Mutex
+	return LogSinkFunc(func(entry LogEntry) {
+		data, err := json.Marshal(entry.ToMap())
+		if err != nil {
+			data, _ = json.Marshal(map[string]interface{}{
+				"logType":   LogTypeName(int(entry.LogType)),
+				"timestamp": entry.Timestamp.UTC().Format("2006-01-02T15:04:05.999Z"),
+				"message":   entry.Message,
+				"error":     err.Error(),
+			})
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(data, '\n'))
+	})
+}
+
+/* =================== */
+
+type RingBufferSink interface {
+	LogSink
+	Entries() []LogEntry
+	Reset()
+}
+
+func NewRingBufferSink(size int) RingBufferSink {
+	if size < 1 {
+		size = 1
+	}
+	return &ringBufferSink{
+		entries: make([]LogEntry, size),
+	}
+}
+
+type ringBufferSink struct {
+	sync.RWMutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func (rb *ringBufferSink) Write(entry LogEntry) {
+	rb.Lock()
+	defer rb.Unlock()
+	rb.entries[rb.next] = entry
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.next == 0 {
+		rb.full = true
+	}
+}
+
+func (rb *ringBufferSink) Entries() []LogEntry {
+	rb.RLock()
+	defer rb.RUnlock()
+	if !rb.full {
+		return append([]LogEntry{}, rb.entries[:rb.next]...)
+	}
+	return append(append([]LogEntry{}, rb.entries[rb.next:]...), rb.entries[:rb.next]...)
+}
+
+func (rb *ringBufferSink) Reset() {
+	rb.Lock()
+	defer rb.Unlock()
+	rb.next = 0
+	rb.full = false
+}
+
+/* =================== */
+
+func NewLogger(level LogType, sinks ...LogSink) Logger {
+	return newLogger(level, sinks...)
+}
+
+func newLogger(level LogType, sinks ...LogSink) *logger {
+	return &logger{
+		config: &loggerConfig{
+			level: level,
+			sinks: sinks,
+			clock: SystemClock(),
+		},
+	}
+}
+
+type loggerConfig struct {
+	sync.RWMutex
+	level LogType
+	sinks []LogSink
+	clock Clock
+}
+
+type logger struct {
+	config *loggerConfig
+	fields Parameters
+}
+
+func (l *logger) setClock(clock Clock) {
+	l.config.Lock()
+	defer l.config.Unlock()
+	l.config.clock = clock
+}
+
+func (l *logger) SetLogLevel(level LogType) {
+	l.config.Lock()
+	defer l.config.Unlock()
+	l.config.level = level
+}
+
+func (l *logger) LogLevel() LogType {
+	l.config.RLock()
+	defer l.config.RUnlock()
+	return l.config.level
+}
+
+func (l *logger) SetLogSinks(sinks ...LogSink) {
+	l.config.Lock()
+	defer l.config.Unlock()
+	l.config.sinks = sinks
+}
+
+func (l *logger) AddLogSink(sink LogSink) {
+	l.config.Lock()
+	defer l.config.Unlock()
+	l.config.sinks = append(l.config.sinks, sink)
+}
+
+func (l *logger) log(logType LogType, msg string, a ...interface{}) string {
+	if len(a) > 0 {
+		msg = fmt.Sprintf(msg, a...)
+	}
+	l.config.RLock()
+	defer l.config.RUnlock()
+	if logType < l.config.level {
+		return msg
+	}
+	entry := LogEntry{
+		LogType:   logType,
+		Timestamp: l.config.clock.Now().UTC(),
+		Message:   msg,
+		Fields:    l.fields,
+	}
+	for _, sink := range l.config.sinks {
+		sink.Write(entry)
+	}
+	return msg
+}
+
+func (l *logger) DEBUG(msg string, a ...interface{}) {
+	l.log(DEBUG, msg, a...)
+}
+
+func (l *logger) INFO(msg string, a ...interface{}) {
+	l.log(INFO, msg, a...)
+}
+
+func (l *logger) WARN(msg string, a ...interface{}) {
+	l.log(WARNING, msg, a...)
+}
+
+func (l *logger) ERROR(msg string, a ...interface{}) {
+	l.log(ERROR, msg, a...)
+}
+
+func (l *logger) FATAL(msg string, a ...interface{}) {
+	l.log(FATAL, msg, a...)
+	os.Exit(1)
+}
+
+func (l *logger) PANIC(msg string, a ...interface{}) {
+	panic(l.log(PANIC, msg, a...))
+}
+
+func (l *logger) WithField(name string, value interface{}) Logger {
+	return l.WithFields(NewParameter(name, value))
+}
+
+func (l *logger) WithFields(params ...Parameter) Logger {
+	fields := make(Parameters, len(l.fields)+len(params))
+	for name, value := range l.fields {
+		fields[name] = value
+	}
+	for _, param := range params {
+		fields.AddParameter(param)
+	}
+	return &logger{
+		config: l.config,
+		fields: fields,
 	}
 }
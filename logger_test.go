@@ -0,0 +1,153 @@
+package goflow_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func messages(entries []goflow.LogEntry) []string {
+	result := make([]string, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.Message
+	}
+	return result
+}
+
+func TestLogLevel(t *testing.T) {
+	ring := goflow.NewRingBufferSink(10)
+	logger := goflow.NewLogger(goflow.WARNING, ring)
+	logger.DEBUG("debug")
+	logger.INFO("info")
+	logger.WARN("warn %v", 1)
+	logger.ERROR("error")
+
+	entries := ring.Entries()
+	if !reflect.DeepEqual(messages(entries), []string{"warn 1", "error"}) {
+		t.Fatalf("unexpected entries %v", messages(entries))
+	}
+	if entries[0].LogType != goflow.WARNING || entries[1].LogType != goflow.ERROR {
+		t.Errorf("unexpected log types %v, %v", entries[0].LogType, entries[1].LogType)
+	}
+}
+
+func TestSystemLogLevel(t *testing.T) {
+	ring := goflow.NewRingBufferSink(10)
+	clock := goflow.NewManualClock(time.Unix(60, 0))
+	sys := goflow.NewSystem(goflow.WithLogLevel(goflow.ERROR), goflow.WithLogSinks(ring), goflow.WithSystemClock(clock))
+	sys.WARN("dropped")
+	sys.SetLogLevel(goflow.WARNING)
+	sys.WARN("kept")
+
+	entries := ring.Entries()
+	if !reflect.DeepEqual(messages(entries), []string{"kept"}) {
+		t.Fatalf("unexpected entries %v", messages(entries))
+	}
+	// entries are stamped by the clock of the system
+	if !entries[0].Timestamp.Equal(time.Unix(60, 0)) {
+		t.Errorf("unexpected timestamp %v", entries[0].Timestamp)
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	ring := goflow.NewRingBufferSink(10)
+	logger := goflow.NewLogger(goflow.DEBUG, ring)
+	withA := logger.WithField("a", 1)
+	withAB := withA.WithFields(goflow.NewParameter("b", 2), goflow.NewParameter("a", 3))
+	logger.INFO("none")
+	withA.INFO("a")
+	withAB.INFO("ab")
+
+	expected := []goflow.Parameters{
+		nil,
+		{"a": 1},
+		{"a": 3, "b": 2},
+	}
+	entries := ring.Entries()
+	if len(entries) != len(expected) {
+		t.Fatalf("unexpected entries %v", messages(entries))
+	}
+	for i, entry := range entries {
+		if len(entry.Fields) != len(expected[i]) || (len(expected[i]) > 0 && !reflect.DeepEqual(entry.Fields, expected[i])) {
+			t.Errorf("expected fields %v for %v, got %v", expected[i], entry.Message, entry.Fields)
+		}
+	}
+}
+
+func entry() goflow.LogEntry {
+	return goflow.LogEntry{
+		LogType:   goflow.INFO,
+		Timestamp: time.Unix(0, int64(500*time.Millisecond)),
+		Message:   "started",
+		Fields:    goflow.Parameters{"b": 2, "a": "x"},
+	}
+}
+
+func TestTextSink(t *testing.T) {
+	var buf bytes.Buffer
+	goflow.NewTextSink(&buf).Write(entry())
+	if expected := "1970-01-01T00:00:00.5Z [INFO] started a=x b=2\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	goflow.NewJSONSink(&buf).Write(entry())
+	var v map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"logType":   "INFO",
+		"timestamp": "1970-01-01T00:00:00.5Z",
+		"message":   "started",
+		"fields":    map[string]interface{}{"a": "x", "b": float64(2)},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+}
+
+func TestRingBufferSink(t *testing.T) {
+	ring := goflow.NewRingBufferSink(3)
+	write := func(msgs ...string) {
+		for _, msg := range msgs {
+			ring.Write(goflow.LogEntry{Message: msg})
+		}
+	}
+
+	write("1", "2")
+	if got := messages(ring.Entries()); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("unexpected entries %v", got)
+	}
+	write("3")
+	if got := messages(ring.Entries()); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("unexpected entries %v", got)
+	}
+	// the oldest entries are overwritten, Entries keeps the write order
+	write("4", "5")
+	if got := messages(ring.Entries()); !reflect.DeepEqual(got, []string{"3", "4", "5"}) {
+		t.Errorf("unexpected entries after wrap-around %v", got)
+	}
+
+	ring.Reset()
+	if got := ring.Entries(); len(got) != 0 {
+		t.Errorf("expected no entries after reset, got %v", messages(got))
+	}
+	write("6")
+	if got := messages(ring.Entries()); !reflect.DeepEqual(got, []string{"6"}) {
+		t.Errorf("unexpected entries %v", got)
+	}
+
+	single := goflow.NewRingBufferSink(0)
+	single.Write(goflow.LogEntry{Message: "a"})
+	single.Write(goflow.LogEntry{Message: "b"})
+	if got := messages(single.Entries()); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("expected a buffer of at least one entry, got %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package goflow
+
+import "sync"
+
+type signalKind int
+
+const (
+	pushSignal signalKind = iota
+	errorSignal
+	completeSignal
+	requestSignal
+	cancelSignal
+)
+
+type stageSignal struct {
+	kind signalKind
+	pipe *pipe
+	data interface{}
+	n    uint64
+}
+
+func (s *stageSignal) dispatch() {
+	switch s.kind {
+	case pushSignal:
+		s.pipe.consumer.OnPush(s.data)
+	case errorSignal:
+		s.pipe.consumer.OnError(s.data.(error))
+	case completeSignal:
+		s.pipe.consumer.OnComplete()
+	case requestSignal:
+		s.pipe.producer.OnRequest(s.n)
+	case cancelSignal:
+		s.pipe.producer.OnCancel()
+	}
+}
+
+func (s *stageSignal) terminal() bool {
+	return s.kind == errorSignal || s.kind == completeSignal || s.kind == cancelSignal
+}
+
+// mailbox serializes all signals addressed to one stage. A single
+// goroutine per stage dispatches the signals till the stage receives or
+// emits a terminal signal. Afterwards the mailbox is transient, late signals are
+// drained by a goroutine that exits as soon as the mailbox is empty.
+type mailbox struct {
+	sync.Mutex
+	queue     []stageSignal
+	spare     []stageSignal
+	running   bool
+	transient bool
+	wakeup    chan struct{}
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{
+		wakeup: make(chan struct{}, 1),
+	}
+}
+
+func (m *mailbox) enqueue(s stageSignal) {
+	m.Lock()
+	m.queue = append(m.queue, s)
+	if m.running {
+		m.Unlock()
+		m.wake()
+		return
+	}
+	m.running = true
+	transient := m.transient
+	m.Unlock()
+	if transient {
+		go m.drain()
+		return
+	}
+	go m.serve()
+}
+
+func (m *mailbox) wake() {
+	select {
+	case m.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// retire makes the mailbox transient once the stage signalled its own
+// termination downstream
+func (m *mailbox) retire() {
+	m.Lock()
+	m.transient = true
+	m.Unlock()
+	m.wake()
+}
+
+// serve dispatches signals till a terminal signal was dispatched and the
+// mailbox is empty, it waits for wakeups meanwhile
+func (m *mailbox) serve() {
+	for {
+		m.Lock()
+		if len(m.queue) == 0 {
+			if m.transient {
+				m.running = false
+				m.Unlock()
+				return
+			}
+			m.Unlock()
+			<-m.wakeup
+			continue
+		}
+		batch := m.queue
+		m.queue = m.spare[:0]
+		m.Unlock()
+
+		terminal := false
+		for i := range batch {
+			batch[i].dispatch()
+			terminal = terminal || batch[i].terminal()
+			batch[i] = stageSignal{}
+		}
+
+		m.Lock()
+		m.spare = batch[:0]
+		m.transient = m.transient || terminal
+		m.Unlock()
+	}
+}
+
+func (m *mailbox) drain() {
+	for {
+		m.Lock()
+		if len(m.queue) == 0 {
+			m.running = false
+			m.Unlock()
+			return
+		}
+		batch := m.queue
+		m.queue = m.spare[:0]
+		m.Unlock()
+
+		for i := range batch {
+			batch[i].dispatch()
+			batch[i] = stageSignal{}
+		}
+
+		m.Lock()
+		m.spare = batch[:0]
+		m.Unlock()
+	}
+}
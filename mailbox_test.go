@@ -0,0 +1,48 @@
+package goflow
+
+import (
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMailboxGoroutinesExit(t *testing.T) {
+	defaultSystem()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		i := 0
+		source := NewProducerFunc(func() (interface{}, error) {
+			if i++; i > 10 {
+				return nil, io.EOF
+			}
+			return uint64(i), nil
+		})
+		<-source.Map(func(v uint64) uint64 { return v * 2 }).To(Slice()).Run()
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at most %v goroutines after completion, got %v", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCancelledPipelineGoroutinesExit(t *testing.T) {
+	defaultSystem()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		runnable := Sequence().Map(func(v uint64) uint64 { return v * 2 }).To(Ignore())
+		result := runnable.Run()
+		runnable.Close()
+		<-result
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at most %v goroutines after cancel, got %v", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,212 @@
+package goflow
+
+import (
+	"io"
+	"runtime/debug"
+	"sync"
+)
+
+// MapAsync runs f for up to parallelism elements concurrently and emits
+// the results in the order of the elements. Returning io.EOF drops the
+// element, other errors are passed to the decider, which either resumes
+// with the next element or fails the stage.
+func MapAsync(parallelism int, f func(interface{}) (interface{}, error), options ...FlowOption) Flow {
+	return newMapAsync(parallelism, true, f, options...)
+}
+
+// MapAsyncUnordered runs f for up to parallelism elements concurrently and
+// emits the results as soon as they are available.
+func MapAsyncUnordered(parallelism int, f func(interface{}) (interface{}, error), options ...FlowOption) Flow {
+	return newMapAsync(parallelism, false, f, options...)
+}
+
+func newMapAsync(parallelism int, ordered bool, f func(interface{}) (interface{}, error), options ...FlowOption) *mapAsync {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &mapAsync{
+		flowSettings: newFlowSettings(options),
+		f:            f,
+		parallelism:  parallelism,
+		ordered:      ordered,
+	}
+}
+
+type asyncResult struct {
+	value interface{}
+	err   error
+	ready bool
+}
+
+type mapAsync struct {
+	sync.Mutex
+	flowSettings
+	inlet        Inlet
+	outlet       Outlet
+	f            func(interface{}) (interface{}, error)
+	parallelism  int
+	ordered      bool
+	demand       uint64
+	requested    int
+	running      int
+	results      []*asyncResult
+	upstreamDone bool
+	done         bool
+}
+
+func (m *mapAsync) OnSubscribe(inlet Inlet) {
+	m.Lock()
+	defer m.Unlock()
+	m.inlet = inlet
+}
+
+func (m *mapAsync) OnPush(v interface{}) {
+	m.Lock()
+	defer m.Unlock()
+	if m.done {
+		return
+	}
+	if m.requested > 0 {
+		m.requested--
+	}
+	r := &asyncResult{}
+	m.running++
+	if m.ordered {
+		m.results = append(m.results, r)
+	}
+	go m.invoke(r, v)
+}
+
+func (m *mapAsync) invoke(r *asyncResult, v interface{}) {
+	value, err := m.call(v)
+	m.Lock()
+	defer m.Unlock()
+	m.running--
+	if m.done {
+		return
+	}
+	if err != nil && err != io.EOF {
+		switch m.decider(err) {
+		case ResumeDirective, RestartDirective:
+			// there is no state to restart, both drop the element
+			m.deadLetter(v, m, err)
+			err = io.EOF
+		default:
+			m.fail(err)
+			return
+		}
+	}
+	r.value, r.err, r.ready = value, err, true
+	if !m.ordered {
+		m.results = append(m.results, r)
+	}
+	m.emit()
+}
+
+func (m *mapAsync) call(v interface{}) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = taskPanicError(r, debug.Stack()).WithTimestamp(m.currentClock().Now())
+		}
+	}()
+	return m.f(v)
+}
+
+func (m *mapAsync) OnError(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.upstreamDone = true
+	if m.done {
+		return
+	}
+	m.fail(err)
+}
+
+func (m *mapAsync) OnComplete() {
+	m.Lock()
+	defer m.Unlock()
+	m.upstreamDone = true
+	if m.done {
+		return
+	}
+	m.emit()
+}
+
+func (m *mapAsync) Subscribe(outlet Outlet) {
+	m.Lock()
+	defer m.Unlock()
+	m.outlet = outlet
+}
+
+func (m *mapAsync) OnRequest(n uint64) {
+	m.Lock()
+	defer m.Unlock()
+	if m.done {
+		return
+	}
+	m.demand = addDemand(m.demand, n)
+	m.emit()
+}
+
+func (m *mapAsync) OnCancel() {
+	m.Lock()
+	defer m.Unlock()
+	if m.done {
+		return
+	}
+	m.done = true
+	m.results = nil
+	if !m.upstreamDone {
+		m.inlet.Cancel()
+	}
+	m.outlet.Complete()
+}
+
+func (m *mapAsync) fail(err error) {
+	m.done = true
+	m.results = nil
+	if !m.upstreamDone {
+		m.inlet.Cancel()
+	}
+	m.outlet.Error(err)
+}
+
+// emit pushes the available results as far as demand allows, completes
+// the stage once upstream is done and nothing is pending and requests as
+// many elements as there are free and demanded slots
+func (m *mapAsync) emit() {
+	for len(m.results) > 0 && m.results[0].ready {
+		r := m.results[0]
+		if r.err == nil {
+			if m.demand == 0 {
+				break
+			}
+			m.demand--
+			m.outlet.Push(r.value)
+		}
+		m.results[0] = nil
+		m.results = m.results[1:]
+	}
+	if m.upstreamDone {
+		if m.running == 0 && len(m.results) == 0 {
+			m.done = true
+			m.outlet.Complete()
+		}
+		return
+	}
+	occupied := m.requested + len(m.results)
+	if !m.ordered {
+		occupied += m.running
+	}
+	// every occupied slot consumes downstream demand, so never request
+	// more than is demanded
+	slots := uint64(m.parallelism)
+	if m.demand < slots {
+		slots = m.demand
+	}
+	if uint64(occupied) < slots {
+		n := slots - uint64(occupied)
+		m.requested += int(n)
+		m.inlet.Request(n)
+	}
+}
@@ -1,6 +1,9 @@
 package goflow
 
-import "sync"
+import (
+	"math"
+	"sync"
+)
 
 type Outlet interface {
 	Push(interface{})
@@ -10,9 +13,17 @@ type Outlet interface {
 
 type Inlet interface {
 	Pull()
+	Request(uint64)
 	Cancel()
 }
 
+func addDemand(a uint64, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
 type Pipe interface {
 	Inlet
 	Outlet
@@ -20,10 +31,13 @@ type Pipe interface {
 }
 
 func NewGraph(producer Producer) Graph {
+	return newGraph(producer, newMailbox())
+}
+
+func newGraph(producer Producer, upstream *mailbox) *pipe {
 	p := &pipe{
 		producer: producer,
-		inbound:  make(chan interface{}),
-		outbound: make(chan interface{}),
+		upstream: upstream,
 	}
 	producer.Subscribe(p)
 	return p
@@ -31,77 +45,57 @@ func NewGraph(producer Producer) Graph {
 
 type pipe struct {
 	sync.Mutex
-	consumer Consumer
-	producer Producer
-	inbound  chan interface{}
-	outbound chan interface{}
-}
-
-func (p *pipe) run() {
-	go func() {
-		for {
-			select {
-			case incmd := <-p.inbound:
-				switch incmd.(type) {
-				case PushCommand:
-					pc := incmd.(PushCommand)
-					go func() {
-						p.consumer.OnPush(pc.Data)
-					}()
-				case ErrorCommand:
-					ec := incmd.(ErrorCommand)
-					go func() {
-						p.consumer.OnError(ec.Error)
-					}()
-				case CompleteCommand:
-					go func() {
-						p.consumer.OnComplete()
-					}()
-					return
-				}
-			case outcmd := <-p.outbound:
-				switch outcmd.(type) {
-				case PullCommand:
-					go func() {
-						p.producer.OnPull()
-					}()
-				case CancelCommand:
-					go func() {
-						p.producer.OnCancel()
-					}()
-				}
-			}
-		}
-	}()
+	consumer   Consumer
+	producer   Producer
+	upstream   *mailbox
+	downstream *mailbox
+	sources    []*pipe
+}
+
+// systemBinder is implemented by stages which use the system the graph
+// runs in, unless a system was set explicitly
+type systemBinder interface {
+	bindSystem(*system)
+}
+
+// bindSystem hands the system to the producer of the pipe and all stages
+// upstream of it, consumers call it before they request the first element
+func (p *pipe) bindSystem(sys *system) {
+	if b, ok := p.producer.(systemBinder); ok {
+		b.bindSystem(sys)
+	}
+	for _, source := range p.sources {
+		source.bindSystem(sys)
+	}
 }
 
 func (p *pipe) Push(v interface{}) {
-	go func() {
-		p.inbound <- Push(v)
-	}()
+	p.downstream.enqueue(stageSignal{kind: pushSignal, pipe: p, data: v})
 }
 
 func (p *pipe) Error(err error) {
-	go func() {
-		p.inbound <- ErrorCmd(err)
-	}()
+	p.downstream.enqueue(stageSignal{kind: errorSignal, pipe: p, data: err})
+	p.upstream.retire()
 }
 
 func (p *pipe) Complete() {
-	// blocks till last command is piped
-	p.inbound <- Complete()
+	p.downstream.enqueue(stageSignal{kind: completeSignal, pipe: p})
+	p.upstream.retire()
 }
 
 func (p *pipe) Pull() {
-	go func() {
-		p.outbound <- Pull()
-	}()
+	p.Request(1)
+}
+
+func (p *pipe) Request(n uint64) {
+	if n == 0 {
+		return
+	}
+	p.upstream.enqueue(stageSignal{kind: requestSignal, pipe: p, n: n})
 }
 
 func (p *pipe) Cancel() {
-	go func() {
-		p.outbound <- Cancel()
-	}()
+	p.upstream.enqueue(stageSignal{kind: cancelSignal, pipe: p})
 }
 
 func (p *pipe) Take(a uint64) Graph {
@@ -116,20 +110,66 @@ func (p *pipe) Filter(f interface{}) Graph {
 	return p.Via(NewFlowFunc(variadicFilterFunc(f)))
 }
 
+func (p *pipe) Recover(f func(error) interface{}) Graph {
+	return p.Via(Recover(f))
+}
+
+func (p *pipe) RecoverWithRetries(n int, fallback func(error) Graph) Graph {
+	return p.Via(RecoverWithRetries(n, fallback))
+}
+
 func (p *pipe) Via(flow Flow) Graph {
 	p.Lock()
 	defer p.Unlock()
+	// both sides of a flow share one mailbox, so the stage is never
+	// called concurrently
+	box := newMailbox()
 	p.consumer = flow
+	p.downstream = box
 	p.consumer.OnSubscribe(p)
-	p.run()
-	return NewGraph(flow)
+	next := newGraph(flow, box)
+	next.sources = []*pipe{p}
+	return next
 }
 
 func (p *pipe) To(consumer RunnableConsumer) Runnable {
 	p.Lock()
 	defer p.Unlock()
 	p.consumer = consumer
+	p.downstream = newMailbox()
 	p.consumer.OnSubscribe(p)
-	p.run()
 	return consumer
 }
+
+func (p *pipe) Broadcast(n int) []Graph {
+	return p.fanOut(NewFanOut(), n)
+}
+
+func (p *pipe) BroadcastQuorum(n int, quorum int) []Graph {
+	return p.fanOut(NewFanOutQuorum(quorum), n)
+}
+
+func (p *pipe) Balance(n int) []Graph {
+	return p.fanOut(NewBalance(), n)
+}
+
+type junction interface {
+	Consumer
+	Branch() Graph
+}
+
+func (p *pipe) fanOut(j junction, n int) []Graph {
+	p.Lock()
+	defer p.Unlock()
+	p.consumer = j
+	p.downstream = newMailbox()
+	branches := make([]Graph, n)
+	for i := range branches {
+		branches[i] = j.Branch()
+		if branch, ok := branches[i].(*pipe); ok {
+			branch.sources = []*pipe{p}
+		}
+	}
+	p.consumer.OnSubscribe(p)
+	return branches
+}
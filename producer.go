@@ -8,7 +8,7 @@ import (
 
 type Producer interface {
 	Subscribe(Outlet)
-	OnPull()
+	OnRequest(uint64)
 	OnCancel()
 }
 
@@ -18,6 +18,13 @@ type Source interface {
 	OnClose()
 }
 
+// cancellableSource is implemented by sources whose OnPull may block, cancel
+// is called when the producer is cancelled while OnPull is running and must
+// make it return
+type cancellableSource interface {
+	cancel()
+}
+
 type SourceFunc func() (interface{}, error)
 
 func (sf SourceFunc) OnInit() {}
@@ -40,8 +47,12 @@ func NewProducerFunc(f func() (interface{}, error)) Graph {
 
 type producer struct {
 	sync.Mutex
-	outlet Outlet
-	source Source
+	outlet    Outlet
+	source    Source
+	demand    uint64
+	emitting  bool
+	cancelled bool
+	completed bool
 }
 
 func (p *producer) Subscribe(outlet Outlet) {
@@ -51,20 +62,83 @@ func (p *producer) Subscribe(outlet Outlet) {
 	p.source.OnInit()
 }
 
-func (p *producer) OnPull() {
-	data, err := p.source.OnPull()
-	if err != nil {
-		if err == io.EOF {
-			p.outlet.Complete()
+func (p *producer) OnRequest(n uint64) {
+	p.Lock()
+	if p.completed {
+		p.Unlock()
+		return
+	}
+	p.demand = addDemand(p.demand, n)
+	if p.emitting {
+		p.Unlock()
+		return
+	}
+	p.emitting = true
+	p.Unlock()
+	go p.emit()
+}
+
+// emit pushes elements as long as there is outstanding demand, only one
+// emit loop is running at a time and cancel is observed between elements
+func (p *producer) emit() {
+	for {
+		p.Lock()
+		if p.cancelled {
+			p.completed = true
+			p.Unlock()
+			p.close()
 			return
 		}
-		p.outlet.Error(err)
-		return
+		if p.demand == 0 {
+			p.emitting = false
+			p.Unlock()
+			return
+		}
+		p.demand--
+		p.Unlock()
+
+		data, err := p.source.OnPull()
+		if err != nil {
+			p.Lock()
+			p.completed = true
+			cancelled := p.cancelled
+			p.Unlock()
+			if cancelled {
+				p.close()
+				return
+			}
+			if err == io.EOF {
+				p.outlet.Complete()
+				return
+			}
+			p.outlet.Error(err)
+			return
+		}
+		p.outlet.Push(data)
 	}
-	p.outlet.Push(data)
 }
 
 func (p *producer) OnCancel() {
+	p.Lock()
+	if p.completed {
+		p.Unlock()
+		return
+	}
+	p.cancelled = true
+	if p.emitting {
+		// the running emit loop closes the source
+		p.Unlock()
+		if src, ok := p.source.(cancellableSource); ok {
+			src.cancel()
+		}
+		return
+	}
+	p.completed = true
+	p.Unlock()
+	p.close()
+}
+
+func (p *producer) close() {
 	defer p.outlet.Complete()
 	p.source.OnClose()
 }
@@ -100,7 +174,11 @@ func (s *sequence) OnClose() {}
 
 func ChanProducer(in <-chan interface{}) Graph {
 	return NewProducerFunc(func() (interface{}, error) {
-		return <-in, nil
+		v, open := <-in
+		if !open {
+			return nil, io.EOF
+		}
+		return v, nil
 	})
 }
 
@@ -0,0 +1,252 @@
+package goflow
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Recover turns an upstream error into one last element and completes the
+// stream. If f returns nil the error is propagated downstream.
+func Recover(f func(error) interface{}) Flow {
+	return &recoverFlow{
+		recover: f,
+	}
+}
+
+// RecoverWithRetries continues with the graph returned by fallback when
+// upstream fails, at most n times. Outstanding demand is transferred to the
+// fallback graph. If fallback returns nil or the retries are exhausted the
+// error is propagated downstream.
+func RecoverWithRetries(n int, fallback func(error) Graph) Flow {
+	return &recoverFlow{
+		fallback: fallback,
+		retries:  n,
+	}
+}
+
+type recoverFlow struct {
+	sync.Mutex
+	inlet     Inlet
+	outlet    Outlet
+	recover   func(error) interface{}
+	fallback  func(error) Graph
+	retries   int
+	current   int
+	demand    uint64
+	recovered interface{}
+	done      bool
+}
+
+func (rf *recoverFlow) OnSubscribe(inlet Inlet) {
+	rf.Lock()
+	defer rf.Unlock()
+	rf.inlet = inlet
+}
+
+func (rf *recoverFlow) OnPush(v interface{}) {
+	rf.push(0, v)
+}
+
+func (rf *recoverFlow) OnError(err error) {
+	rf.fail(0, err)
+}
+
+func (rf *recoverFlow) OnComplete() {
+	rf.complete(0)
+}
+
+func (rf *recoverFlow) Subscribe(outlet Outlet) {
+	rf.Lock()
+	defer rf.Unlock()
+	rf.outlet = outlet
+}
+
+func (rf *recoverFlow) OnRequest(n uint64) {
+	rf.Lock()
+	defer rf.Unlock()
+	if rf.recovered != nil {
+		rf.emitRecovered()
+		return
+	}
+	if rf.done {
+		return
+	}
+	rf.demand = addDemand(rf.demand, n)
+	rf.inlet.Request(n)
+}
+
+func (rf *recoverFlow) OnCancel() {
+	rf.Lock()
+	defer rf.Unlock()
+	if rf.recovered != nil {
+		rf.recovered = nil
+		rf.done = true
+		rf.outlet.Complete()
+		return
+	}
+	if rf.done {
+		return
+	}
+	rf.inlet.Cancel()
+}
+
+// push, fail and complete ignore signals of upstreams that were already
+// replaced by a fallback
+func (rf *recoverFlow) push(upstream int, v interface{}) {
+	rf.Lock()
+	defer rf.Unlock()
+	if rf.done || upstream != rf.current {
+		return
+	}
+	if rf.demand > 0 {
+		rf.demand--
+	}
+	rf.outlet.Push(v)
+}
+
+func (rf *recoverFlow) fail(upstream int, err error) {
+	rf.Lock()
+	defer rf.Unlock()
+	if rf.done || upstream != rf.current {
+		return
+	}
+	rf.done = true
+	if rf.recover != nil {
+		if rf.recovered = rf.recover(err); rf.recovered != nil {
+			if rf.demand > 0 {
+				rf.emitRecovered()
+			}
+			return
+		}
+	}
+	if rf.fallback != nil && rf.retries > 0 {
+		if g := rf.fallback(err); g != nil {
+			rf.retries--
+			rf.current++
+			rf.done = false
+			in := &recoverInput{
+				flow:     rf,
+				upstream: rf.current,
+			}
+			g.To(in)
+			rf.inlet = in.inlet
+			if rf.demand > 0 {
+				rf.inlet.Request(rf.demand)
+			}
+			return
+		}
+	}
+	rf.outlet.Error(err)
+}
+
+func (rf *recoverFlow) complete(upstream int) {
+	rf.Lock()
+	defer rf.Unlock()
+	if rf.done || upstream != rf.current {
+		return
+	}
+	rf.done = true
+	rf.outlet.Complete()
+}
+
+func (rf *recoverFlow) emitRecovered() {
+	v := rf.recovered
+	rf.recovered = nil
+	rf.outlet.Push(v)
+	rf.outlet.Complete()
+}
+
+type recoverInput struct {
+	flow     *recoverFlow
+	upstream int
+	inlet    Inlet
+}
+
+// OnSubscribe is called while the flow is locked during the switch to the
+// fallback
+func (in *recoverInput) OnSubscribe(inlet Inlet) {
+	in.inlet = inlet
+}
+
+func (in *recoverInput) OnPush(v interface{}) {
+	in.flow.push(in.upstream, v)
+}
+
+func (in *recoverInput) OnError(err error) {
+	in.flow.fail(in.upstream, err)
+}
+
+func (in *recoverInput) OnComplete() {
+	in.flow.complete(in.upstream)
+}
+
+func (in *recoverInput) Run() <-chan interface{} {
+	return nil
+}
+
+func (in *recoverInput) Close() {
+	in.inlet.Cancel()
+}
+
+/* =================== */
+
+// Retry handles an element again if task fails, at most attempts times.
+// Between the attempts it waits min, doubled for every attempt up to max,
+// on the clock set by WithClock or the clock of the system the flow runs in.
+func Retry(task Task, attempts int, min time.Duration, max time.Duration, options ...FlowOption) Task {
+	return &retryTask{
+		Task:         task,
+		flowSettings: newFlowSettings(options),
+		attempts:     attempts,
+		min:          min,
+		max:          max,
+	}
+}
+
+type retryTask struct {
+	Task
+	flowSettings
+	attempts int
+	min      time.Duration
+	max      time.Duration
+}
+
+func (rt *retryTask) OnHandle(v interface{}) (interface{}, error) {
+	result, err := rt.Task.OnHandle(v)
+	for i := 0; err != nil && err != io.EOF && i < rt.attempts; i++ {
+		sleep(rt.currentClock(), backoff(rt.min, rt.max, i))
+		result, err = rt.Task.OnHandle(v)
+	}
+	return result, err
+}
+
+/* =================== */
+
+// OnErrorResume skips every element task fails on if decider accepts the
+// error, all other errors still terminate the flow.
+func OnErrorResume(task Task, decider func(interface{}, error) bool) Task {
+	return &resumeTask{
+		Task:    task,
+		decider: decider,
+	}
+}
+
+type resumeTask struct {
+	Task
+	decider func(interface{}, error) bool
+}
+
+func (rt *resumeTask) bindSystem(sys *system) {
+	if b, ok := rt.Task.(systemBinder); ok {
+		b.bindSystem(sys)
+	}
+}
+
+func (rt *resumeTask) OnHandle(v interface{}) (interface{}, error) {
+	result, err := rt.Task.OnHandle(v)
+	if err != nil && err != io.EOF && rt.decider(v, err) {
+		return nil, io.EOF
+	}
+	return result, err
+}
@@ -0,0 +1,53 @@
+package goflow
+
+import (
+	"sort"
+	"sync"
+)
+
+type resource interface {
+	resourceName() string
+	shutdown()
+	kill()
+	done() <-chan struct{}
+}
+
+type registry struct {
+	sync.Mutex
+	resources map[resource]bool
+}
+
+func newRegistry() *registry {
+	return &registry{
+		resources: make(map[resource]bool),
+	}
+}
+
+func (r *registry) register(res resource) {
+	r.Lock()
+	r.resources[res] = true
+	r.Unlock()
+	go func() {
+		<-res.done()
+		r.unregister(res)
+	}()
+}
+
+func (r *registry) unregister(res resource) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.resources, res)
+}
+
+func (r *registry) snapshot() []resource {
+	r.Lock()
+	defer r.Unlock()
+	result := make([]resource, 0, len(r.resources))
+	for res := range r.resources {
+		result = append(result, res)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].resourceName() < result[j].resourceName()
+	})
+	return result
+}
@@ -0,0 +1,96 @@
+package goflow
+
+import (
+	"time"
+)
+
+type Directive int
+
+const (
+	RestartDirective Directive = iota
+	StopDirective
+	EscalateDirective
+	ResumeDirective
+)
+
+type SupervisorStrategy interface {
+	Decide(failure *Error, restarts int) (Directive, time.Duration)
+}
+
+type SupervisorFunc func(*Error, int) (Directive, time.Duration)
+
+func (f SupervisorFunc) Decide(failure *Error, restarts int) (Directive, time.Duration) {
+	return f(failure, restarts)
+}
+
+/* =================== */
+
+func RestartOnPanic() SupervisorStrategy {
+	return SupervisorFunc(func(*Error, int) (Directive, time.Duration) {
+		return RestartDirective, 0
+	})
+}
+
+/* =================== */
+
+func RestartWithBackoff(min time.Duration, max time.Duration) SupervisorStrategy {
+	return SupervisorFunc(func(_ *Error, restarts int) (Directive, time.Duration) {
+		return RestartDirective, backoff(min, max, restarts)
+	})
+}
+
+// backoff doubles min for every attempt, capped at max
+func backoff(min time.Duration, max time.Duration, attempt int) time.Duration {
+	delay := min
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+/* =================== */
+
+func EscalateToParent() SupervisorStrategy {
+	return SupervisorFunc(func(*Error, int) (Directive, time.Duration) {
+		return EscalateDirective, 0
+	})
+}
+
+/* =================== */
+
+func StopOnPanic() SupervisorStrategy {
+	return SupervisorFunc(func(*Error, int) (Directive, time.Duration) {
+		return StopDirective, 0
+	})
+}
+
+/* =================== */
+
+func WithSupervisor(strategy SupervisorStrategy) BehaviorOption {
+	return func(bh *behaviorHandler) {
+		if strategy != nil {
+			bh.supervisor = strategy
+		}
+	}
+}
+
+func WithParent(parent Ref) BehaviorOption {
+	return func(bh *behaviorHandler) {
+		bh.parent = parent
+	}
+}
+
+func panicError(v interface{}, stack []byte) *Error {
+	return newError("Behavior panicked", "Behavior.Handle panicked while processing a call", "GF-0201").
+		AddMeta("panic", v).
+		AddMeta("stack", string(stack))
+}
+
+func taskPanicError(v interface{}, stack []byte) *Error {
+	return newError("Task panicked", "Task.OnHandle panicked while processing an element", "GF-0305").
+		AddMeta("panic", v).
+		AddMeta("stack", string(stack))
+}
@@ -0,0 +1,117 @@
+package goflow_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+// request fails the test if ref does not reply within a second
+func request(t *testing.T, ref goflow.Ref, v interface{}) (interface{}, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := ref.RequestContext(ctx, v)
+	if err == context.DeadlineExceeded {
+		t.Fatalf("no reply for %v", v)
+	}
+	return res, err
+}
+
+func expectPanic(t *testing.T, err error) *goflow.Error {
+	t.Helper()
+	failure, ok := err.(*goflow.Error)
+	if !ok {
+		t.Fatalf("expected the panic as *Error, got %v", err)
+	}
+	return failure
+}
+
+func TestPanicErrorMeta(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewBehaviorHandler(panicking{})
+	defer handler.Stop()
+
+	_, err := request(t, handler.Ref(), "panic")
+	failure := expectPanic(t, err)
+	if failure.Code != "GF-0201" {
+		t.Errorf("expected GF-0201, got %v", failure.Code)
+	}
+	if v, _ := failure.GetMeta("panic"); v != "panicking behavior" {
+		t.Errorf("expected the panic value, got %v", v)
+	}
+	stack, _ := failure.GetMeta("stack")
+	if s, ok := stack.(string); !ok || !strings.Contains(s, "panicking.Handle") {
+		t.Errorf("expected the stack of the panic, got %v", stack)
+	}
+}
+
+func TestRestartOnPanic(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewBehaviorHandler(panicking{}, goflow.WithSupervisor(goflow.RestartOnPanic()))
+	defer handler.Stop()
+	ref := handler.Ref()
+
+	for i := 0; i < 3; i++ {
+		if _, err := request(t, ref, "panic"); err == nil {
+			t.Fatal("expected the panic as error")
+		}
+		if v, err := request(t, ref, "ok"); v != "ok" || err != nil {
+			t.Fatalf("expected ok after restart %v, got %v, %v", i, v, err)
+		}
+	}
+}
+
+func TestStopOnPanic(t *testing.T) {
+	sys := goflow.NewSystem()
+	handler := sys.NewBehaviorHandler(panicking{}, goflow.WithSupervisor(goflow.StopOnPanic()))
+	defer handler.Stop()
+	ref := handler.Ref()
+
+	if _, err := request(t, ref, "panic"); err == nil {
+		t.Fatal("expected the panic as error")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := request(t, ref, "ok"); err != goflow.ErrorBehaviorStopped {
+			t.Errorf("expected %v, got %v", goflow.ErrorBehaviorStopped, err)
+		}
+	}
+}
+
+func TestEscalateToParent(t *testing.T) {
+	sys := goflow.NewSystem()
+	escalated := make(chan interface{}, 1)
+	parent := sys.NewBehaviorHandler(goflow.BehaviorFunc(func(v interface{}) (interface{}, error) {
+		escalated <- v
+		return goflow.None(), nil
+	}))
+	defer parent.Stop()
+	child := sys.NewBehaviorHandler(panicking{},
+		goflow.WithSupervisor(goflow.EscalateToParent()),
+		goflow.WithParent(parent.Ref()),
+	)
+	defer child.Stop()
+
+	_, err := request(t, child.Ref(), "panic")
+	failure := expectPanic(t, err)
+	select {
+	case v := <-escalated:
+		cmd, ok := v.(goflow.FailureCommand)
+		if !ok {
+			t.Fatalf("expected a FailureCommand, got %v", v)
+		}
+		if cmd.Error != failure {
+			t.Errorf("expected the escalated %v, got %v", failure, cmd.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failure was not escalated")
+	}
+
+	// the child stops after escalating
+	if _, err := request(t, child.Ref(), "ok"); err != goflow.ErrorBehaviorStopped {
+		t.Errorf("expected %v, got %v", goflow.ErrorBehaviorStopped, err)
+	}
+}
@@ -1,80 +1,244 @@
 package goflow
 
 import (
-	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
 
 var (
-	sys = newSystem()
+	defaultSys     *system
+	defaultSysOnce sync.Once
 )
 
-func System() FlowSystem { return sys }
+func defaultSystem() *system {
+	defaultSysOnce.Do(func() {
+		defaultSys = newSystem()
+	})
+	return defaultSys
+}
+
+func System() FlowSystem { return defaultSystem() }
 
 type FlowSystem interface {
 	Logger
-	Terminate()
-	TerminateWithTimeout(time.Duration)
+	SetLogLevel(LogType)
+	LogLevel() LogType
+	SetLogSinks(...LogSink)
+	AddLogSink(LogSink)
+	NewBehaviorHandler(Behavior, ...BehaviorOption) BehaviorHandler
+	NewStatefulBehaviorHandler(StatefulBehavior, ...BehaviorOption) BehaviorHandler
+	Clock() Clock
+	NewTopic() Topic
+	DeadLetters() Topic
+	Run(Runnable) <-chan interface{}
+	Spawn(string, Behavior, ...BehaviorOption) (BehaviorHandler, error)
+	SpawnStateful(string, StatefulBehavior, ...BehaviorOption) (BehaviorHandler, error)
+	Lookup(string) (Ref, bool)
+	HandleSignals(...os.Signal)
+	Terminate() error
+	TerminateWithTimeout(time.Duration) error
 	Terminated() <-chan int
 }
 
-func newSystem() FlowSystem {
-	sys := &system{
-		exitChan: make(chan int, 1),
+type SystemOption func(*system)
+
+func WithSignals(sigs ...os.Signal) SystemOption {
+	return func(sys *system) {
+		sys.HandleSignals(sigs...)
 	}
+}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+func WithLogLevel(level LogType) SystemOption {
+	return func(sys *system) {
+		sys.SetLogLevel(level)
+	}
+}
 
-	go func() {
-		signal := <-sigs
-		log.Printf("GoFlow ends by %v", signal)
-		sys.Terminate()
-	}()
+func WithLogSinks(sinks ...LogSink) SystemOption {
+	return func(sys *system) {
+		sys.SetLogSinks(sinks...)
+	}
+}
+
+// WithSystemClock sets the clock of the system, its logger, behaviors and
+// of flows running within the system.
+func WithSystemClock(clock Clock) SystemOption {
+	return func(sys *system) {
+		if clock != nil {
+			sys.clock = clock
+			sys.setClock(clock)
+		}
+	}
+}
+
+func WithTerminationTimeout(timeout time.Duration) SystemOption {
+	return func(sys *system) {
+		if timeout > 0 {
+			sys.terminationTimeout = timeout
+		}
+	}
+}
+
+func NewSystem(options ...SystemOption) FlowSystem {
+	return newSystem(options...)
+}
 
+func newSystem(options ...SystemOption) *system {
+	sys := &system{
+		logger:             newLogger(INFO, StderrSink()),
+		exitChan:           make(chan int, 1),
+		resources:          newRegistry(),
+		clock:              SystemClock(),
+		terminationTimeout: 10 * time.Second,
+	}
+	sys.deadLetters = newTopic(nil)
+	sys.resources.register(sys.deadLetters)
+	sys.root = newBehaviorHandler(BehaviorFunc(sys.guard), nil, func(bh *behaviorHandler) {
+		bh.system = sys
+	})
+	sys.user, _ = sys.root.spawn("user", BehaviorFunc(sys.guard), nil)
+	for _, option := range options {
+		option(sys)
+	}
 	return sys
 }
 
 type system struct {
-	exitChan chan int
+	*logger
+	exitChan           chan int
+	root               *behaviorHandler
+	user               *behaviorHandler
+	resources          *registry
+	deadLetters        *topic
+	clock              Clock
+	terminationTimeout time.Duration
+	signalOnce         sync.Once
+	terminateOnce      sync.Once
+	terminateErr       error
 }
 
-func (sys *system) Terminate() {
-	sys.TerminateWithTimeout(10 * time.Second) // TODO configure sys termination timeout
+func (sys *system) guard(v interface{}) (interface{}, error) {
+	if failure, ok := v.(FailureCommand); ok {
+		sys.ERROR("Escalated failure reached guardian: %v", failure.Error)
+	}
+	return None(), nil
 }
 
-func (sys *system) TerminateWithTimeout(time.Duration) {
-	// TODO close all registered behaviors
-	sys.exitChan <- 0
+func (sys *system) Clock() Clock {
+	return sys.clock
 }
 
-func (sys *system) Terminated() <-chan int {
-	return sys.exitChan
+func (sys *system) NewBehaviorHandler(behavior Behavior, options ...BehaviorOption) BehaviorHandler {
+	return newAnonymousBehaviorHandler(sys, behavior, nil, options...)
 }
 
-func (sys *system) DEBUG(msg string, a ...interface{}) {
-
+func (sys *system) NewStatefulBehaviorHandler(behavior StatefulBehavior, options ...BehaviorOption) BehaviorHandler {
+	return newAnonymousBehaviorHandler(sys, nil, newActor(behavior), options...)
 }
 
-func (sys *system) INFO(msg string, a ...interface{}) {
+func (sys *system) NewTopic() Topic {
+	t := newTopic(sys)
+	sys.resources.register(t)
+	return t
+}
 
+type systemRunnable interface {
+	runIn(*system) <-chan interface{}
 }
 
-func (sys *system) WARN(msg string, a ...interface{}) {
+func (sys *system) Run(runnable Runnable) <-chan interface{} {
+	if r, ok := runnable.(systemRunnable); ok {
+		return r.runIn(sys)
+	}
+	return runnable.Run()
+}
 
+func (sys *system) Spawn(name string, behavior Behavior, options ...BehaviorOption) (BehaviorHandler, error) {
+	return sys.user.Spawn(name, behavior, options...)
 }
 
-func (sys *system) ERROR(msg string, a ...interface{}) {
+func (sys *system) SpawnStateful(name string, behavior StatefulBehavior, options ...BehaviorOption) (BehaviorHandler, error) {
+	return sys.user.SpawnStateful(name, behavior, options...)
+}
 
+// Lookup returns the ref the handler at path shares with its children, it
+// is closed together with the handler
+func (sys *system) Lookup(path string) (Ref, bool) {
+	if handler, ok := sys.root.lookup(path); ok {
+		return handler.self(), true
+	}
+	return nil, false
 }
 
-func (sys *system) FATAL(msg string, a ...interface{}) {
+func (sys *system) HandleSignals(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	sys.signalOnce.Do(func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, sigs...)
+
+		go func() {
+			select {
+			case sig := <-sigChan:
+				sys.INFO("GoFlow ends by %v", sig)
+				sys.Terminate()
+			case <-sys.root.Terminated():
+			}
+			signal.Stop(sigChan)
+		}()
+	})
+}
 
+func (sys *system) Terminate() error {
+	return sys.TerminateWithTimeout(sys.terminationTimeout)
 }
 
-func (sys *system) PANIC(msg string, a ...interface{}) {
+func (sys *system) TerminateWithTimeout(timeout time.Duration) error {
+	sys.terminateOnce.Do(func() {
+		resources := append(sys.resources.snapshot(), sys.root)
+		for _, res := range resources {
+			res.shutdown()
+		}
+
+		expired := make(chan struct{})
+		deadline := sys.clock.AfterFunc(timeout, func() {
+			close(expired)
+		})
+		defer deadline.Stop()
+	wait:
+		for _, res := range resources {
+			select {
+			case <-res.done():
+			case <-expired:
+				break wait
+			}
+		}
+
+		pending := make([]string, 0)
+		for _, res := range resources {
+			select {
+			case <-res.done():
+			default:
+				pending = append(pending, res.resourceName())
+				res.kill()
+			}
+		}
+
+		if len(pending) > 0 {
+			sys.WARN("GoFlow terminated with %v unfinished resources: %v", len(pending), pending)
+			sys.terminateErr = terminationError(pending).WithTimestamp(sys.clock.Now())
+			sys.exitChan <- 1
+			return
+		}
+		sys.exitChan <- 0
+	})
+	return sys.terminateErr
+}
 
+func (sys *system) Terminated() <-chan int {
+	return sys.exitChan
 }
@@ -0,0 +1,131 @@
+package goflow_test
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func expectExit(t *testing.T, sys goflow.FlowSystem, code int) {
+	t.Helper()
+	select {
+	case v := <-sys.Terminated():
+		if v != code {
+			t.Errorf("expected exit code %v, got %v", code, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("system did not terminate")
+	}
+}
+
+func expectRunning(t *testing.T, sys goflow.FlowSystem) {
+	t.Helper()
+	select {
+	case v := <-sys.Terminated():
+		t.Fatalf("system terminated with %v", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSystemsAreIsolated(t *testing.T) {
+	a, b := goflow.NewSystem(), goflow.NewSystem()
+	defer b.Terminate()
+	// names are unique per system only
+	spawn(t, a, "worker")
+	spawn(t, b, "worker")
+	refA, _ := a.Lookup("/user/worker")
+	refB, _ := b.Lookup("/user/worker")
+	if refA == refB {
+		t.Fatal("systems share the worker")
+	}
+
+	lettersA, lettersB := deadLetters(a), deadLetters(b)
+	a.NewTopic().Publish("lost")
+	expectDeadLetter(t, lettersA, "lost", goflow.ErrorNoSubscribers)
+	select {
+	case v := <-lettersB:
+		t.Errorf("dead letter of a reached b: %v", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := a.Terminate(); err != nil {
+		t.Fatal(err)
+	}
+	expectExit(t, a, 0)
+	expectRunning(t, b)
+	if _, err := request(t, refA, "a"); err == nil {
+		t.Error("expected the worker of the terminated system to reject calls")
+	}
+	if v, err := request(t, refB, "b"); v != "b" || err != nil {
+		t.Errorf("expected b, got %v, %v", v, err)
+	}
+}
+
+func TestSignalHandlingIsOptIn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent on windows")
+	}
+	plain := goflow.NewSystem()
+	defer plain.Terminate()
+	handling := goflow.NewSystem(goflow.WithSignals(os.Interrupt))
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	expectExit(t, handling, 0)
+	expectRunning(t, plain)
+}
+
+func TestTerminationReportsPending(t *testing.T) {
+	sys := goflow.NewSystem(goflow.WithTerminationTimeout(50 * time.Millisecond))
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	handler := sys.NewBehaviorHandler(goflow.BehaviorFunc(func(v interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return v, nil
+	}))
+	handler.Ref().Send("block")
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("behavior did not start")
+	}
+
+	err := sys.Terminate()
+	failure, ok := err.(*goflow.Error)
+	if !ok || failure.Code != "GF-0301" {
+		t.Fatalf("expected a termination timeout, got %v", err)
+	}
+	pending, _ := failure.GetMeta("pending")
+	names, _ := pending.([]string)
+	found := false
+	for _, name := range names {
+		found = found || strings.HasPrefix(name, "behavior(")
+	}
+	if !found {
+		t.Errorf("expected the blocked behavior to be pending, got %v", pending)
+	}
+	expectExit(t, sys, 1)
+	if again := sys.Terminate(); again != err {
+		t.Errorf("expected the same error on repeated termination, got %v", again)
+	}
+}
+
+func TestTerminationWithinTimeout(t *testing.T) {
+	sys := goflow.NewSystem(goflow.WithTerminationTimeout(time.Second))
+	spawn(t, sys, "worker")
+	sys.NewBehaviorHandler(echo())
+	if err := sys.Terminate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expectExit(t, sys, 0)
+}
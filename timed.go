@@ -0,0 +1,636 @@
+package goflow
+
+import (
+	"sync"
+	"time"
+)
+
+// timedBufferSize bounds the elements a time based stage holds
+const timedBufferSize = 16
+
+// timedFlow is the common base of time based stages. It tracks downstream
+// demand and upstream requests and owns at most one timer, a replaced or
+// stopped timer never fires its function.
+type timedFlow struct {
+	sync.Mutex
+	flowSettings
+	inlet        Inlet
+	outlet       Outlet
+	demand       uint64
+	requested    uint64
+	started      bool
+	upstreamDone bool
+	done         bool
+	timer        Timer
+	generation   int
+}
+
+func newTimedFlow(options []FlowOption) timedFlow {
+	return timedFlow{
+		flowSettings: newFlowSettings(options),
+	}
+}
+
+func (t *timedFlow) OnSubscribe(inlet Inlet) {
+	t.Lock()
+	defer t.Unlock()
+	t.inlet = inlet
+}
+
+func (t *timedFlow) Subscribe(outlet Outlet) {
+	t.Lock()
+	defer t.Unlock()
+	t.outlet = outlet
+}
+
+func (t *timedFlow) schedule(d time.Duration, f func()) {
+	t.stopTimer()
+	generation := t.generation
+	t.timer = t.currentClock().AfterFunc(d, func() {
+		t.Lock()
+		defer t.Unlock()
+		if t.done || generation != t.generation {
+			return
+		}
+		t.timer = nil
+		f()
+	})
+}
+
+func (t *timedFlow) stopTimer() {
+	t.generation++
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+func (t *timedFlow) push(v interface{}) {
+	t.demand--
+	t.outlet.Push(v)
+}
+
+func (t *timedFlow) received() {
+	if t.requested > 0 {
+		t.requested--
+	}
+}
+
+// fill requests elements till occupied plus requested covers the demand,
+// at most size
+func (t *timedFlow) fill(size uint64, occupied uint64) {
+	if t.upstreamDone {
+		return
+	}
+	if t.demand < size {
+		size = t.demand
+	}
+	if occupied += t.requested; occupied < size {
+		t.requested += size - occupied
+		t.inlet.Request(size - occupied)
+	}
+}
+
+func (t *timedFlow) finish() {
+	t.done = true
+	t.stopTimer()
+	t.outlet.Complete()
+}
+
+func (t *timedFlow) fail(err error) {
+	t.done = true
+	t.stopTimer()
+	if !t.upstreamDone {
+		t.inlet.Cancel()
+	}
+	t.outlet.Error(err)
+}
+
+func (t *timedFlow) cancel() {
+	t.done = true
+	t.stopTimer()
+	if !t.upstreamDone {
+		t.inlet.Cancel()
+	}
+	t.outlet.Complete()
+}
+
+func (t *timedFlow) OnError(err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.upstreamDone = true
+	if t.done {
+		return
+	}
+	t.fail(err)
+}
+
+func (t *timedFlow) OnCancel() {
+	t.Lock()
+	defer t.Unlock()
+	if t.done {
+		return
+	}
+	t.cancel()
+}
+
+/* =================== */
+
+// Delay emits every element d after it was received.
+func Delay(d time.Duration, options ...FlowOption) Flow {
+	return &delay{
+		timedFlow: newTimedFlow(options),
+		delay:     d,
+	}
+}
+
+type delayed struct {
+	value interface{}
+	due   time.Time
+}
+
+type delay struct {
+	timedFlow
+	delay time.Duration
+	queue []delayed
+}
+
+func (dl *delay) OnPush(v interface{}) {
+	dl.Lock()
+	defer dl.Unlock()
+	if dl.done {
+		return
+	}
+	dl.received()
+	dl.queue = append(dl.queue, delayed{value: v, due: dl.currentClock().Now().Add(dl.delay)})
+	dl.update()
+}
+
+func (dl *delay) OnComplete() {
+	dl.Lock()
+	defer dl.Unlock()
+	dl.upstreamDone = true
+	if dl.done {
+		return
+	}
+	dl.update()
+}
+
+func (dl *delay) OnRequest(n uint64) {
+	dl.Lock()
+	defer dl.Unlock()
+	if dl.done {
+		return
+	}
+	dl.demand = addDemand(dl.demand, n)
+	dl.update()
+}
+
+func (dl *delay) update() {
+	now := dl.currentClock().Now()
+	for len(dl.queue) > 0 && dl.demand > 0 && !dl.queue[0].due.After(now) {
+		dl.push(dl.queue[0].value)
+		dl.queue[0] = delayed{}
+		dl.queue = dl.queue[1:]
+	}
+	if dl.upstreamDone && len(dl.queue) == 0 {
+		dl.finish()
+		return
+	}
+	if len(dl.queue) > 0 && dl.timer == nil && dl.queue[0].due.After(now) {
+		dl.schedule(dl.queue[0].due.Sub(now), dl.update)
+	}
+	dl.fill(timedBufferSize, uint64(len(dl.queue)))
+}
+
+/* =================== */
+
+// Throttle emits at most elements per duration, with bursts of up to burst
+// elements after idle periods. Upstream is backpressured meanwhile.
+func Throttle(elements int, per time.Duration, burst int, options ...FlowOption) Flow {
+	if elements < 1 {
+		elements = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	interval := per / time.Duration(elements)
+	if interval <= 0 {
+		interval = 1
+	}
+	return &throttle{
+		timedFlow: newTimedFlow(options),
+		interval:  interval,
+		capacity:  burst,
+		tokens:    burst,
+	}
+}
+
+type throttle struct {
+	timedFlow
+	interval time.Duration
+	capacity int
+	tokens   int
+	last     time.Time
+	queue    []interface{}
+}
+
+func (th *throttle) OnPush(v interface{}) {
+	th.Lock()
+	defer th.Unlock()
+	if th.done {
+		return
+	}
+	th.received()
+	th.queue = append(th.queue, v)
+	th.update()
+}
+
+func (th *throttle) OnComplete() {
+	th.Lock()
+	defer th.Unlock()
+	th.upstreamDone = true
+	if th.done {
+		return
+	}
+	th.update()
+}
+
+func (th *throttle) OnRequest(n uint64) {
+	th.Lock()
+	defer th.Unlock()
+	if th.done {
+		return
+	}
+	th.demand = addDemand(th.demand, n)
+	th.update()
+}
+
+// refill adds a token for every elapsed interval, up to the capacity
+func (th *throttle) refill(now time.Time) {
+	if th.last.IsZero() {
+		th.last = now
+	}
+	if n := int(now.Sub(th.last) / th.interval); n > 0 {
+		th.tokens += n
+		th.last = th.last.Add(time.Duration(n) * th.interval)
+	}
+	if th.tokens >= th.capacity {
+		th.tokens = th.capacity
+		th.last = now
+	}
+}
+
+func (th *throttle) update() {
+	now := th.currentClock().Now()
+	for len(th.queue) > 0 && th.demand > 0 {
+		th.refill(now)
+		if th.tokens == 0 {
+			if th.timer == nil {
+				th.schedule(th.last.Add(th.interval).Sub(now), th.update)
+			}
+			break
+		}
+		th.tokens--
+		th.push(th.queue[0])
+		th.queue[0] = nil
+		th.queue = th.queue[1:]
+	}
+	if th.upstreamDone && len(th.queue) == 0 {
+		th.finish()
+		return
+	}
+	th.fill(uint64(th.capacity), uint64(len(th.queue)))
+}
+
+/* =================== */
+
+// Debounce emits an element only after no other element was received for
+// d. On completion the last element is emitted as soon as there is demand.
+// Upstream is consumed as long as there is demand, superseded elements are
+// dropped.
+func Debounce(d time.Duration, options ...FlowOption) Flow {
+	return &debounce{
+		timedFlow: newTimedFlow(options),
+		quiet:     d,
+	}
+}
+
+type debounce struct {
+	timedFlow
+	quiet   time.Duration
+	latest  interface{}
+	pending bool
+	ready   bool
+}
+
+func (db *debounce) OnPush(v interface{}) {
+	db.Lock()
+	defer db.Unlock()
+	if db.done {
+		return
+	}
+	db.received()
+	db.latest, db.pending, db.ready = v, true, false
+	db.schedule(db.quiet, func() {
+		db.ready = true
+		db.update()
+	})
+	db.update()
+}
+
+func (db *debounce) OnComplete() {
+	db.Lock()
+	defer db.Unlock()
+	db.upstreamDone = true
+	if db.done {
+		return
+	}
+	db.stopTimer()
+	db.ready = db.pending
+	db.update()
+}
+
+func (db *debounce) OnRequest(n uint64) {
+	db.Lock()
+	defer db.Unlock()
+	if db.done {
+		return
+	}
+	db.demand = addDemand(db.demand, n)
+	db.update()
+}
+
+func (db *debounce) update() {
+	if db.ready && db.demand > 0 {
+		db.push(db.latest)
+		db.latest, db.pending, db.ready = nil, false, false
+	}
+	if db.upstreamDone {
+		// the last element waits for demand
+		if !db.pending {
+			db.finish()
+		}
+		return
+	}
+	if db.demand > 0 && db.requested == 0 {
+		db.requested = 1
+		db.inlet.Request(1)
+	}
+}
+
+/* =================== */
+
+// Sample emits the latest element received within every period d, on
+// completion the last element is emitted once there is demand. Upstream is
+// consumed as long as there is demand, elements not sampled are dropped.
+func Sample(d time.Duration, options ...FlowOption) Flow {
+	return &sample{
+		timedFlow: newTimedFlow(options),
+		period:    d,
+	}
+}
+
+type sample struct {
+	timedFlow
+	period time.Duration
+	latest interface{}
+	has    bool
+}
+
+func (s *sample) OnPush(v interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if s.done {
+		return
+	}
+	s.received()
+	s.latest, s.has = v, true
+	s.pull()
+}
+
+func (s *sample) OnComplete() {
+	s.Lock()
+	defer s.Unlock()
+	s.upstreamDone = true
+	if s.done {
+		return
+	}
+	s.stopTimer()
+	s.flush()
+}
+
+func (s *sample) OnRequest(n uint64) {
+	s.Lock()
+	defer s.Unlock()
+	if s.done {
+		return
+	}
+	s.demand = addDemand(s.demand, n)
+	if s.upstreamDone {
+		s.flush()
+		return
+	}
+	if !s.started {
+		s.started = true
+		s.schedule(s.period, s.tick)
+	}
+	s.pull()
+}
+
+func (s *sample) tick() {
+	if s.has && s.demand > 0 {
+		s.push(s.latest)
+		s.latest, s.has = nil, false
+	}
+	s.schedule(s.period, s.tick)
+	s.pull()
+}
+
+// flush emits the last element after completion as soon as there is
+// demand and completes
+func (s *sample) flush() {
+	if s.has {
+		if s.demand == 0 {
+			return
+		}
+		s.push(s.latest)
+		s.latest, s.has = nil, false
+	}
+	s.finish()
+}
+
+func (s *sample) pull() {
+	if s.demand > 0 && s.requested == 0 {
+		s.requested = 1
+		s.inlet.Request(1)
+	}
+}
+
+/* =================== */
+
+// IdleTimeout fails the stream with ErrorIdleTimeout if no element passed
+// for d.
+func IdleTimeout(d time.Duration, options ...FlowOption) Flow {
+	return &timeout{
+		timedFlow: newTimedFlow(options),
+		timeout:   d,
+		idle:      true,
+		err:       ErrorIdleTimeout,
+	}
+}
+
+// CompletionTimeout fails the stream with ErrorCompletionTimeout if it did
+// not complete within d after the first request.
+func CompletionTimeout(d time.Duration, options ...FlowOption) Flow {
+	return &timeout{
+		timedFlow: newTimedFlow(options),
+		timeout:   d,
+		err:       ErrorCompletionTimeout,
+	}
+}
+
+type timeout struct {
+	timedFlow
+	timeout time.Duration
+	idle    bool
+	err     error
+}
+
+func (to *timeout) OnPush(v interface{}) {
+	to.Lock()
+	defer to.Unlock()
+	if to.done {
+		return
+	}
+	if to.idle {
+		to.arm()
+	}
+	to.outlet.Push(v)
+}
+
+func (to *timeout) OnComplete() {
+	to.Lock()
+	defer to.Unlock()
+	to.upstreamDone = true
+	if to.done {
+		return
+	}
+	to.finish()
+}
+
+func (to *timeout) OnRequest(n uint64) {
+	to.Lock()
+	defer to.Unlock()
+	if to.done {
+		return
+	}
+	if !to.started {
+		to.started = true
+		to.arm()
+	}
+	to.inlet.Request(n)
+}
+
+func (to *timeout) arm() {
+	to.schedule(to.timeout, func() {
+		to.fail(to.err)
+	})
+}
+
+/* =================== */
+
+// GroupedWithin emits the elements in slices of up to size elements, a
+// slice is emitted when it is full or d elapsed since the last emission,
+// whichever happens first. Empty slices are never emitted.
+func GroupedWithin(size int, d time.Duration, options ...FlowOption) Flow {
+	if size < 1 {
+		size = 1
+	}
+	return &groupedWithin{
+		timedFlow: newTimedFlow(options),
+		size:      size,
+		window:    d,
+	}
+}
+
+type groupedWithin struct {
+	timedFlow
+	size   int
+	window time.Duration
+	buffer []interface{}
+	due    bool
+}
+
+func (gw *groupedWithin) OnPush(v interface{}) {
+	gw.Lock()
+	defer gw.Unlock()
+	if gw.done {
+		return
+	}
+	gw.received()
+	gw.buffer = append(gw.buffer, v)
+	gw.update()
+}
+
+func (gw *groupedWithin) OnComplete() {
+	gw.Lock()
+	defer gw.Unlock()
+	gw.upstreamDone = true
+	if gw.done {
+		return
+	}
+	gw.due = true
+	gw.update()
+}
+
+func (gw *groupedWithin) OnRequest(n uint64) {
+	gw.Lock()
+	defer gw.Unlock()
+	if gw.done {
+		return
+	}
+	gw.demand = addDemand(gw.demand, n)
+	if !gw.started {
+		gw.started = true
+		gw.schedule(gw.window, gw.tick)
+	}
+	gw.update()
+}
+
+func (gw *groupedWithin) tick() {
+	gw.due = len(gw.buffer) > 0
+	gw.schedule(gw.window, gw.tick)
+	gw.update()
+}
+
+// update emits a full or due group if there is demand and requests the
+// elements missing for the next group
+func (gw *groupedWithin) update() {
+	if (len(gw.buffer) >= gw.size || gw.due) && len(gw.buffer) > 0 && gw.demand > 0 {
+		n := len(gw.buffer)
+		if n > gw.size {
+			n = gw.size
+		}
+		gw.push(gw.buffer[:n:n])
+		gw.buffer = append([]interface{}(nil), gw.buffer[n:]...)
+		gw.due = false
+		if !gw.upstreamDone {
+			// the next group gets a full window
+			gw.schedule(gw.window, gw.tick)
+		}
+	}
+	if gw.upstreamDone {
+		if len(gw.buffer) == 0 {
+			gw.finish()
+		} else {
+			gw.due = true
+		}
+		return
+	}
+	if gw.demand > 0 && len(gw.buffer)+int(gw.requested) < gw.size {
+		n := gw.size - len(gw.buffer) - int(gw.requested)
+		gw.requested += uint64(n)
+		gw.inlet.Request(uint64(n))
+	}
+}
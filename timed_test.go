@@ -0,0 +1,69 @@
+package goflow_test
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+// slowAck acknowledges every element after d and records them
+type slowAck struct {
+	sync.Mutex
+	d        time.Duration
+	elements []interface{}
+}
+
+func (s *slowAck) Handle(v interface{}) (interface{}, error) {
+	time.Sleep(s.d)
+	s.Lock()
+	defer s.Unlock()
+	s.elements = append(s.elements, v)
+	return goflow.Done(), nil
+}
+
+func (s *slowAck) received() []interface{} {
+	s.Lock()
+	defer s.Unlock()
+	return append([]interface{}{}, s.elements...)
+}
+
+// pausing emits a, waits for pause and emits b, Take(2) completes right
+// after b without further demand
+func pausing(pause time.Duration) goflow.Graph {
+	values := []interface{}{"a", "b"}
+	i := 0
+	return goflow.NewProducerFunc(func() (interface{}, error) {
+		if i >= len(values) {
+			return nil, io.EOF
+		}
+		if i > 0 {
+			time.Sleep(pause)
+		}
+		i++
+		return values[i-1], nil
+	})
+}
+
+func TestLastElementWaitsForDemand(t *testing.T) {
+	for name, stage := range map[string]func() goflow.Flow{
+		"Debounce": func() goflow.Flow { return goflow.Debounce(5 * time.Millisecond) },
+		"Sample":   func() goflow.Flow { return goflow.Sample(5 * time.Millisecond) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ack := &slowAck{d: 100 * time.Millisecond}
+			handler := goflow.NewSystem().NewBehaviorHandler(ack)
+			defer handler.Stop()
+			result := pausing(30 * time.Millisecond).Take(2).Via(stage()).To(goflow.BehaviorConsumer(handler.Ref(), goflow.Done())).Run()
+			if v := awaitResult(t, result); v != goflow.Done() {
+				t.Fatalf("unexpected result %v", v)
+			}
+			if received := ack.received(); !reflect.DeepEqual(received, []interface{}{"a", "b"}) {
+				t.Errorf("expected [a b], got %v", received)
+			}
+		})
+	}
+}
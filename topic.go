@@ -1,6 +1,9 @@
 package goflow
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
 type Topic interface {
 	Subscribe(chan<- interface{})
@@ -10,12 +13,22 @@ type Topic interface {
 
 	Publish(interface{})
 	Close()
+	// Done is closed once the topic is closed and stopped delivering
+	Done() <-chan struct{}
 }
 
 func NewTopic() Topic {
+	return defaultSystem().NewTopic()
+}
+
+func newTopic(system *system) *topic {
 	t := &topic{
-		inbound:  make(chan interface{}), // TODO configure size
-		consumer: make(map[chan<- interface{}]bool),
+		system:    system,
+		wakeup:    make(chan struct{}, 1),
+		consumer:  make(map[chan<- interface{}]*subscription),
+		closed:    make(chan struct{}),
+		killed:    make(chan struct{}),
+		completed: make(chan struct{}),
 	}
 	t.run()
 	return t
@@ -23,52 +36,199 @@ func NewTopic() Topic {
 
 type topic struct {
 	sync.RWMutex
-	inbound  chan interface{}
-	consumer map[chan<- interface{}]bool
+	system   *system
+	consumer map[chan<- interface{}]*subscription
+	// published messages are queued in order and delivered by run
+	queueLock sync.Mutex
+	queue     []interface{}
+	sealed    bool
+	wakeup    chan struct{}
+	closed    chan struct{}
+	killed    chan struct{}
+	completed chan struct{}
+	closeOnce sync.Once
+	killOnce  sync.Once
 }
 
 func (t *topic) run() {
 	go func() {
-		for msg := range t.inbound {
-			t.RLock()
-			for c := range t.consumer {
-				c <- msg
+		defer close(t.completed)
+		for {
+			select {
+			case <-t.closed:
+				t.discard(t.dequeue())
+				return
+			case <-t.wakeup:
+				if !t.dispatch(t.dequeue()) {
+					return
+				}
 			}
-			t.RUnlock()
 		}
 	}()
 }
 
+// dispatch delivers msgs in order, messages left when the topic is closed
+// are dead-lettered, it returns false once the topic is killed
+func (t *topic) dispatch(msgs []interface{}) bool {
+	for i, msg := range msgs {
+		select {
+		case <-t.closed:
+			t.discard(msgs[i:])
+			return true
+		default:
+		}
+		subs := t.subscriptions()
+		if len(subs) == 0 {
+			t.system.deadLetter(msg, t, ErrorNoSubscribers)
+		}
+		for _, sub := range subs {
+			if !t.deliver(sub, msg) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (t *topic) dequeue() []interface{} {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+	msgs := t.queue
+	t.queue = nil
+	return msgs
+}
+
+func (t *topic) discard(msgs []interface{}) {
+	for _, msg := range msgs {
+		t.system.deadLetter(msg, t, ErrorTopicClosed)
+	}
+}
+
+func (t *topic) subscriptions() []*subscription {
+	t.RLock()
+	defer t.RUnlock()
+	subs := make([]*subscription, 0, len(t.consumer))
+	for _, sub := range t.consumer {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// deliver blocks until the subscriber accepts msg, unsubscribes or is
+// closed, it returns false once the topic is killed
+func (t *topic) deliver(sub *subscription, msg interface{}) bool {
+	select {
+	case <-sub.closed:
+		t.system.deadLetter(msg, t, ErrorRefClosed)
+		return true
+	default:
+	}
+	select {
+	case sub.c <- msg:
+	case <-sub.closed:
+		t.system.deadLetter(msg, t, ErrorRefClosed)
+	case <-sub.cancelled:
+		t.system.deadLetter(msg, t, ErrorUnsubscribed)
+	case <-t.killed:
+		return false
+	}
+	return true
+}
+
 func (t *topic) Subscribe(c chan<- interface{}) {
+	t.subscribe(c, nil)
+}
+
+func (t *topic) subscribe(c chan<- interface{}, closed <-chan bool) {
 	t.Lock()
 	defer t.Unlock()
 	if _, ok := t.consumer[c]; !ok {
-		t.consumer[c] = true
+		t.consumer[c] = &subscription{
+			c:         c,
+			closed:    closed,
+			cancelled: make(chan struct{}),
+		}
 	}
 }
 
 func (t *topic) Unsubscribe(c chan<- interface{}) {
 	t.Lock()
 	defer t.Unlock()
-	if _, ok := t.consumer[c]; ok {
+	if sub, ok := t.consumer[c]; ok {
+		close(sub.cancelled)
 		delete(t.consumer, c)
 	}
 }
 
 func (t *topic) SubscribeRef(ref Ref) {
-	t.Subscribe(ref.IN())
+	var closed <-chan bool
+	if n, ok := ref.(closeNotifier); ok {
+		closed = n.closing()
+	}
+	t.subscribe(ref.IN(), closed)
 }
 
 func (t *topic) UnsubscribeRef(ref Ref) {
 	t.Unsubscribe(ref.IN())
 }
 
+// Publish does not block, messages are delivered in the order they were
+// published
 func (t *topic) Publish(v interface{}) {
-	go func() {
-		t.inbound <- v
-	}()
+	t.queueLock.Lock()
+	if t.sealed {
+		t.queueLock.Unlock()
+		t.system.deadLetter(v, t, ErrorTopicClosed)
+		return
+	}
+	t.queue = append(t.queue, v)
+	t.queueLock.Unlock()
+	select {
+	case t.wakeup <- struct{}{}:
+	default:
+	}
 }
 
 func (t *topic) Close() {
-	close(t.inbound)
+	t.closeOnce.Do(func() {
+		t.queueLock.Lock()
+		t.sealed = true
+		t.queueLock.Unlock()
+		close(t.closed)
+	})
+}
+
+func (t *topic) Done() <-chan struct{} {
+	return t.completed
+}
+
+func (t *topic) resourceName() string {
+	return fmt.Sprintf("topic(%p)", t)
+}
+
+func (t *topic) shutdown() {
+	t.Close()
+}
+
+func (t *topic) kill() {
+	t.Close()
+	t.killOnce.Do(func() {
+		close(t.killed)
+	})
+}
+
+func (t *topic) done() <-chan struct{} {
+	return t.completed
+}
+
+type subscription struct {
+	c         chan<- interface{}
+	closed    <-chan bool
+	cancelled chan struct{}
+}
+
+// closeNotifier is implemented by subscribers that can be closed while the
+// topic delivers to them
+type closeNotifier interface {
+	closing() <-chan bool
 }
@@ -0,0 +1,103 @@
+package goflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ioswarm/goflow"
+)
+
+func deadLetters(sys goflow.FlowSystem) <-chan interface{} {
+	c := make(chan interface{}, 16)
+	sys.DeadLetters().Subscribe(c)
+	return c
+}
+
+func expectDeadLetter(t *testing.T, letters <-chan interface{}, message interface{}, reason error) {
+	t.Helper()
+	select {
+	case v := <-letters:
+		letter, ok := v.(goflow.DeadLetter)
+		if !ok {
+			t.Fatalf("expected a dead letter, got %v", v)
+		}
+		if letter.Message != message || letter.Reason != reason {
+			t.Errorf("expected %v for %v, got %v for %v", reason, message, letter.Reason, letter.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no dead letter for %v", message)
+	}
+}
+
+func TestTopicDelivers(t *testing.T) {
+	sys := goflow.NewSystem()
+	topic := sys.NewTopic()
+	a, b := make(chan interface{}, 1), make(chan interface{}, 1)
+	topic.Subscribe(a)
+	topic.Subscribe(b)
+	topic.Publish("msg")
+	for _, c := range []chan interface{}{a, b} {
+		select {
+		case v := <-c:
+			if v != "msg" {
+				t.Errorf("expected msg, got %v", v)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+	}
+}
+
+func TestTopicDeadLetters(t *testing.T) {
+	t.Run("no subscribers", func(t *testing.T) {
+		sys := goflow.NewSystem()
+		letters := deadLetters(sys)
+		sys.NewTopic().Publish("nobody")
+		expectDeadLetter(t, letters, "nobody", goflow.ErrorNoSubscribers)
+	})
+
+	t.Run("closed topic", func(t *testing.T) {
+		sys := goflow.NewSystem()
+		letters := deadLetters(sys)
+		topic := sys.NewTopic()
+		topic.Close()
+		<-topic.Done()
+		topic.Publish("late")
+		expectDeadLetter(t, letters, "late", goflow.ErrorTopicClosed)
+	})
+
+	t.Run("closed ref", func(t *testing.T) {
+		sys := goflow.NewSystem()
+		letters := deadLetters(sys)
+		topic := sys.NewTopic()
+		handler := sys.NewBehaviorHandler(echo())
+		defer handler.Stop()
+		ref := handler.Ref()
+		topic.SubscribeRef(ref)
+		ref.Close()
+		topic.Publish("closed")
+		expectDeadLetter(t, letters, "closed", goflow.ErrorRefClosed)
+	})
+
+	t.Run("removed subscriber", func(t *testing.T) {
+		sys := goflow.NewSystem()
+		letters := deadLetters(sys)
+		topic := sys.NewTopic()
+		unread := make(chan interface{})
+		topic.Subscribe(unread)
+		topic.Publish("lost")
+		time.Sleep(20 * time.Millisecond)
+		topic.Unsubscribe(unread)
+		expectDeadLetter(t, letters, "lost", goflow.ErrorUnsubscribed)
+
+		// the topic is not blocked by the removed subscriber
+		other := make(chan interface{}, 1)
+		topic.Subscribe(other)
+		topic.Publish("next")
+		select {
+		case <-other:
+		case <-time.After(time.Second):
+			t.Fatal("topic is blocked")
+		}
+	})
+}
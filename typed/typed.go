@@ -0,0 +1,255 @@
+// Package typed is a type-safe layer on top of goflow. Graphs, flows and
+// sinks carry their element types, so mismatching stages are rejected by
+// the compiler instead of panicking at runtime:
+//
+//	squares, err := typed.To(
+//		typed.Via(typed.Sequence().Take(5), typed.Map(func(v uint64) uint64 { return v * v })),
+//		typed.Slice[uint64](),
+//	).Run()
+//
+// Every typed stage compiles down to the corresponding goflow stage.
+package typed
+
+import (
+	"io"
+
+	"github.com/ioswarm/goflow"
+)
+
+func typeError(v interface{}, expected interface{}) error {
+	return goflow.Errorf("Could not cast %T to %T", v, expected)
+}
+
+func cast[T any](v interface{}) (T, error) {
+	t, ok := v.(T)
+	if !ok {
+		var zero T
+		return zero, typeError(v, zero)
+	}
+	return t, nil
+}
+
+/* =================== */
+
+type Graph[T any] struct {
+	graph goflow.Graph
+}
+
+// From wraps an untyped graph whose elements are of type T.
+func From[T any](g goflow.Graph) Graph[T] {
+	return Graph[T]{graph: g}
+}
+
+func (g Graph[T]) Untyped() goflow.Graph {
+	return g.graph
+}
+
+func (g Graph[T]) Take(n uint64) Graph[T] {
+	return From[T](g.graph.Take(n))
+}
+
+func (g Graph[T]) Filter(f func(T) bool) Graph[T] {
+	return Via(g, Filter(f))
+}
+
+func (g Graph[T]) Via(flow Flow[T, T]) Graph[T] {
+	return Via(g, flow)
+}
+
+func Sequence() Graph[uint64] {
+	return From[uint64](goflow.Sequence())
+}
+
+func NewSequence(start uint64, step uint64) Graph[uint64] {
+	return From[uint64](goflow.NewSequence(start, step))
+}
+
+func NewProducerFunc[T any](f func() (T, error)) Graph[T] {
+	return From[T](goflow.NewProducerFunc(func() (interface{}, error) {
+		return f()
+	}))
+}
+
+func Of[T any](values ...T) Graph[T] {
+	i := 0
+	return From[T](goflow.NewProducerFunc(func() (interface{}, error) {
+		if i >= len(values) {
+			return nil, io.EOF
+		}
+		i++
+		return values[i-1], nil
+	}))
+}
+
+func ChanProducer[T any](in <-chan T) Graph[T] {
+	return From[T](goflow.NewProducerFunc(func() (interface{}, error) {
+		v, open := <-in
+		if !open {
+			return nil, io.EOF
+		}
+		return v, nil
+	}))
+}
+
+/* =================== */
+
+type Flow[In any, Out any] struct {
+	factory func() goflow.Flow
+}
+
+func NewFlowFunc[In any, Out any](f func(In) (Out, error)) Flow[In, Out] {
+	return Flow[In, Out]{
+		factory: func() goflow.Flow {
+			return goflow.NewFlowFunc(untypedFunc(f))
+		},
+	}
+}
+
+// Untyped materializes a fresh untyped flow.
+func (f Flow[In, Out]) Untyped() goflow.Flow {
+	return f.factory()
+}
+
+func Map[In any, Out any](f func(In) Out) Flow[In, Out] {
+	return NewFlowFunc(func(v In) (Out, error) {
+		return f(v), nil
+	})
+}
+
+// Filter drops the elements f rejects, a mismatching element fails the
+// stream like in Map.
+func Filter[T any](f func(T) bool) Flow[T, T] {
+	return NewFlowFunc(func(v T) (T, error) {
+		if !f(v) {
+			return v, io.EOF
+		}
+		return v, nil
+	})
+}
+
+func Take[T any](n uint64) Flow[T, T] {
+	return Flow[T, T]{
+		factory: func() goflow.Flow {
+			return goflow.Take(n)
+		},
+	}
+}
+
+func MapAsync[In any, Out any](parallelism int, f func(In) (Out, error)) Flow[In, Out] {
+	return Flow[In, Out]{
+		factory: func() goflow.Flow {
+			return goflow.MapAsync(parallelism, untypedFunc(f))
+		},
+	}
+}
+
+func MapAsyncUnordered[In any, Out any](parallelism int, f func(In) (Out, error)) Flow[In, Out] {
+	return Flow[In, Out]{
+		factory: func() goflow.Flow {
+			return goflow.MapAsyncUnordered(parallelism, untypedFunc(f))
+		},
+	}
+}
+
+func untypedFunc[In any, Out any](f func(In) (Out, error)) func(interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		in, err := cast[In](v)
+		if err != nil {
+			return nil, err
+		}
+		return f(in)
+	}
+}
+
+func Via[In any, Out any](g Graph[In], flow Flow[In, Out]) Graph[Out] {
+	return From[Out](g.graph.Via(flow.factory()))
+}
+
+/* =================== */
+
+type Sink[T any, R any] struct {
+	// flow is attached in front of the consumer if set
+	flow     func() goflow.Flow
+	consumer func() goflow.RunnableConsumer
+	result   func(interface{}) (R, error)
+}
+
+func Slice[T any]() Sink[T, []T] {
+	return Sink[T, []T]{
+		consumer: goflow.Slice,
+		result: func(v interface{}) ([]T, error) {
+			values, err := cast[[]interface{}](v)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]T, len(values))
+			for i, value := range values {
+				if result[i], err = cast[T](value); err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		},
+	}
+}
+
+// ForEach calls f for every element, a mismatching element fails the
+// stream like in Map.
+func ForEach[T any](f func(T)) Sink[T, goflow.DoneCommand] {
+	return Sink[T, goflow.DoneCommand]{
+		flow: func() goflow.Flow {
+			return goflow.NewFlowFunc(untypedFunc(func(v T) (interface{}, error) {
+				f(v)
+				return nil, io.EOF
+			}))
+		},
+		consumer: goflow.Ignore,
+		result:   cast[goflow.DoneCommand],
+	}
+}
+
+func Ignore[T any]() Sink[T, goflow.DoneCommand] {
+	return ForEach(func(T) {})
+}
+
+/* =================== */
+
+type Runnable[R any] struct {
+	runnable goflow.Runnable
+	result   func(interface{}) (R, error)
+}
+
+func To[T any, R any](g Graph[T], sink Sink[T, R]) Runnable[R] {
+	graph := g.graph
+	if sink.flow != nil {
+		graph = graph.Via(sink.flow())
+	}
+	return Runnable[R]{
+		runnable: graph.To(sink.consumer()),
+		result:   sink.result,
+	}
+}
+
+// Run starts the graph and blocks till its result is available.
+func (r Runnable[R]) Run() (R, error) {
+	return r.await(r.runnable.Run())
+}
+
+// RunIn starts the graph in the given system and blocks till its result
+// is available.
+func (r Runnable[R]) RunIn(system goflow.FlowSystem) (R, error) {
+	return r.await(system.Run(r.runnable))
+}
+
+func (r Runnable[R]) await(result <-chan interface{}) (R, error) {
+	v := <-result
+	if err, ok := v.(error); ok {
+		var zero R
+		return zero, err
+	}
+	return r.result(v)
+}
+
+func (r Runnable[R]) Close() {
+	r.runnable.Close()
+}
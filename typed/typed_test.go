@@ -0,0 +1,105 @@
+package typed_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ioswarm/goflow"
+	"github.com/ioswarm/goflow/typed"
+)
+
+func TestVia(t *testing.T) {
+	squares, err := typed.To(
+		typed.Via(typed.Sequence().Take(4), typed.Map(func(v uint64) uint64 { return v * v })),
+		typed.Slice[uint64](),
+	).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(squares, []uint64{0, 1, 4, 9}) {
+		t.Errorf("unexpected result %v", squares)
+	}
+}
+
+func TestMapChangesType(t *testing.T) {
+	values, err := typed.To(
+		typed.Via(typed.Of(1, 2, 3), typed.Map(strconv.Itoa)),
+		typed.Slice[string](),
+	).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []string{"1", "2", "3"}) {
+		t.Errorf("unexpected result %v", values)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	even, err := typed.To(
+		typed.Sequence().Take(6).Filter(func(v uint64) bool { return v%2 == 0 }),
+		typed.Slice[uint64](),
+	).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(even, []uint64{0, 2, 4}) {
+		t.Errorf("unexpected result %v", even)
+	}
+}
+
+func TestSliceRunIn(t *testing.T) {
+	values, err := typed.To(typed.Of("a", "b"), typed.Slice[string]()).RunIn(goflow.NewSystem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("unexpected result %v", values)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	var seen []string
+	done, err := typed.To(typed.Of("a", "b", "c"), typed.ForEach(func(v string) {
+		seen = append(seen, v)
+	})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done != goflow.Done() {
+		t.Errorf("expected %v, got %v", goflow.Done(), done)
+	}
+	if !reflect.DeepEqual(seen, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected elements %v", seen)
+	}
+}
+
+func TestCastError(t *testing.T) {
+	// the untyped graph lies about its element type, graphs run once so
+	// every case gets its own
+	source := func() typed.Graph[string] {
+		return typed.From[string](goflow.Sequence().Take(1))
+	}
+	expected := "Could not cast uint64 to string"
+
+	t.Run("Map", func(t *testing.T) {
+		_, err := typed.To(typed.Via(source(), typed.Map(func(v string) string { return v })), typed.Slice[string]()).Run()
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		_, err := typed.To(source(), typed.Slice[string]()).Run()
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("ForEach", func(t *testing.T) {
+		_, err := typed.To(source(), typed.ForEach(func(string) {})).Run()
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected %q, got %v", expected, err)
+		}
+	})
+}